@@ -0,0 +1,41 @@
+// Comando mockserver levanta sólo la API mock de Geova, sin la ventana de
+// la simulación. Acepta los mismos flags y archivo de configuración que el
+// simulador (p. ej. -config, -mock-addr, -mock-error-rate).
+package main
+
+import (
+	"errors"
+	"flag"
+	"geova-simulation/config"
+	"geova-simulation/mockserver"
+	"log"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mock := mockserver.New(cfg.Mock, cfg.Endpoints)
+	baseURL, err := mock.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer mock.Close()
+
+	for kind, ep := range cfg.Endpoints {
+		ep.BaseURL = baseURL
+		log.Printf("🧪 %s → %s %s", kind, ep.HTTPMethod(), ep.URL())
+	}
+	log.Println("API mock lista. Ctrl+C para salir.")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+}
@@ -0,0 +1,482 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"geova-simulation/mockserver"
+	"geova-simulation/simulation"
+	"geova-simulation/state"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config reúne las opciones de arranque de la simulación.
+//
+// Precedencia (de menor a mayor): valores por defecto, archivo JSON,
+// variables de entorno y flags de línea de comandos.
+type Config struct {
+	Endpoints simulation.EndpointRegistry `json:"endpoints"`
+	HTTP      simulation.HTTPClientConfig `json:"http"`
+	MPU       simulation.MPUConfig        `json:"mpu"`
+	TFLuna    simulation.TFLunaConfig     `json:"tfluna"`
+	IMX       simulation.IMXConfig        `json:"imx477"`
+	Mock      mockserver.Config           `json:"mock"`
+
+	// Projects son los proyectos simulados a la vez. Las secciones de
+	// sensores de cada proyecto parten de las globales (mpu, tfluna, imx477).
+	Projects []simulation.ProjectConfig `json:"-"`
+	// Captures aplica a todos los proyectos que no definan las suyas.
+	Captures map[simulation.SensorKind]simulation.CaptureConfig `json:"captures"`
+	Fleet    simulation.FleetConfig                             `json:"fleet"`
+	Load     simulation.LoadConfig                              `json:"load"`
+	// Broker vacío usa una cola por sensor (simulation.DefaultBrokerConfig).
+	Broker simulation.BrokerConfig `json:"broker"`
+	// Topology vacía es el backend de Geova (simulation.DefaultTopology).
+	Topology simulation.TopologyConfig `json:"topology"`
+	// Confirm espera el eco del WebSocket antes de marcar Done.
+	Confirm simulation.ConfirmConfig `json:"confirm"`
+	// Metrics sirve /metrics para Prometheus si tiene dirección.
+	Metrics simulation.MetricsConfig `json:"metrics"`
+	// Retention acota los paquetes terminados que quedan en memoria.
+	Retention state.RetentionConfig `json:"retention"`
+
+	// Seed fija los streams aleatorios; SeedSet indica si se fijó a mano
+	// (archivo, GEOVA_SEED o -seed) en vez de tomarse del reloj.
+	Seed    int64 `json:"seed"`
+	SeedSet bool  `json:"-"`
+	// FixedTimestamps cuenta los timestamps desde simulation.SeededEpoch en
+	// vez de tomarlos del reloj.
+	FixedTimestamps bool `json:"fixed_timestamps"`
+
+	// Speed es la velocidad inicial del reloj de la simulación (0, 0.25,
+	// 0.5, 1, 2 o 4; 0 arranca en pausa).
+	Speed float64 `json:"speed"`
+
+	// BatchInterval es la separación entre batches de sensores.
+	BatchInterval time.Duration `json:"-"`
+	Headless      Headless      `json:"-"`
+	Session       Session       `json:"-"`
+}
+
+// Session agrupa los flags de grabación, reproducción y trazas.
+type Session struct {
+	Record      string  // archivo JSONL donde agregar la sesión
+	Replay      string  // sesión grabada a reenviar
+	ReplaySpeed float64 // factor de velocidad del replay
+	Trace       string  // archivo OTLP-JSON con un span por etapa de cada paquete
+}
+
+// Headless agrupa los flags del modo sin ventana.
+type Headless struct {
+	Enabled        bool
+	Duration       time.Duration
+	MaxPackets     int
+	MaxErrorRate   float64
+	ReportInterval time.Duration
+}
+
+// captureFlags acumula los -capture sensor=archivo repetidos.
+type captureFlags []string
+
+func (c *captureFlags) String() string     { return strings.Join(*c, ", ") }
+func (c *captureFlags) Set(v string) error { *c = append(*c, v); return nil }
+
+// headerFlags acumula los -header repetidos.
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
+
+// Default retorna la configuración usada cuando no hay archivo ni flags.
+func Default() *Config {
+	return &Config{
+		Endpoints: simulation.DefaultEndpoints(),
+		HTTP:      simulation.DefaultHTTPClientConfig(),
+		MPU:       simulation.DefaultMPUConfig(),
+		TFLuna:    simulation.DefaultTFLunaConfig(),
+		IMX:       simulation.DefaultIMXConfig(),
+		Mock:      mockserver.DefaultConfig(),
+		Fleet:     simulation.DefaultFleetConfig(),
+		Confirm:   simulation.DefaultConfirmConfig(),
+		Retention: state.DefaultRetentionConfig(),
+
+		Speed:         1,
+		BatchInterval: 2 * time.Second,
+		Headless: Headless{
+			MaxErrorRate:   1,
+			ReportInterval: 5 * time.Second,
+		},
+	}
+}
+
+// Load construye la configuración a partir de args (normalmente os.Args[1:]).
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("geova-simulation", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("GEOVA_CONFIG"), "ruta al archivo de configuración JSON (env GEOVA_CONFIG)")
+	apiURL := fs.String("api-url", "", "URL base de la API para todos los sensores (env GEOVA_API_URL)")
+	var headers headerFlags
+	fs.Var(&headers, "header", "cabecera HTTP extra 'Nombre: valor' (repetible)")
+	httpTimeout := fs.Duration("http-timeout", 0, "timeout total de cada petición HTTP (p. ej. 5s)")
+	maxAttempts := fs.Int("max-attempts", 0, "intentos máximos por paquete en todos los sensores (1 = sin reintentos)")
+	projects := fs.String("projects", "", "IDs de proyecto a simular a la vez, separados por coma (p. ej. 4,7,9)")
+	fleet := fs.Int("fleet", 0, "cantidad de trípodes simulados a la vez, repartidos entre los proyectos")
+	fleetJitter := fs.Float64("fleet-jitter", -1, "variación [0, 1) del intervalo de cada trípode; 0 = todos envían a la vez")
+	var captures captureFlags
+	fs.Var(&captures, "capture", "usar lecturas reales 'sensor=archivo.csv|jsonl' en vez del modelo (repetible)")
+	captureLoop := fs.Bool("capture-loop", false, "volver al principio de las capturas al terminar en vez de detenerse")
+	captureNow := fs.Bool("capture-now", false, "reemplazar el timestamp de las capturas por el del envío")
+	seed := fs.Int64("seed", 0, "semilla para generar payloads reproducibles (env GEOVA_SEED)")
+	fixedTimestamps := fs.Bool("fixed-timestamps", false, "contar los timestamps desde 2024-01-01 en vez del reloj")
+	interval := fs.Duration("interval", 0, "separación entre batches de sensores (por defecto 2s)")
+	rate := fs.Float64("rate", 0, "modo de carga: peticiones por segundo a cada sensor (reemplaza -interval)")
+	concurrency := fs.Int("concurrency", 0, "modo de carga: máximo de paquetes en curso a la vez (0 = sin límite)")
+	rampUp := fs.Duration("ramp-up", 0, "modo de carga: tiempo para subir linealmente desde 0 hasta -rate")
+	summary := fs.String("summary", "", "archivo JSON donde escribir el resumen de latencias al terminar")
+	record := fs.String("record", "", "agregar cada paquete, petición y transición a este archivo de sesión (JSONL)")
+	replay := fs.String("replay", "", "reenviar los paquetes de la última sesión grabada en este archivo")
+	replaySpeed := fs.Float64("replay-speed", 1, "factor de velocidad del replay (2 = el doble de rápido)")
+	trace := fs.String("trace", "", "escribir las trazas de cada paquete en este archivo (OTLP-JSON, una línea por paquete)")
+	brokerCapacity := fs.Int("broker-capacity", 0, "mensajes listos que admite cada cola del broker antes de rechazar (0 = sin cambio)")
+	brokerConsumers := fs.Int("broker-consumers", 0, "consumidores por cola del broker")
+	brokerPrefetch := fs.Int("broker-prefetch", 0, "mensajes sin ack por consumidor del broker")
+	confirm := fs.Bool("confirm", false, "marcar Done sólo cuando el mensaje aparece en el WebSocket de broadcast")
+	confirmURL := fs.String("confirm-url", "", "URL del WebSocket de broadcast (por defecto el de la API mock o ws://localhost:8000/ws)")
+	confirmTimeout := fs.Duration("confirm-timeout", 0, "tiempo máximo de espera del mensaje antes de dar el paquete por perdido (por defecto 10s)")
+	topology := fs.String("topology", "", "archivo JSON con las etapas y aristas del pipeline (reemplaza la sección topology)")
+	metricsAddr := fs.String("metrics-addr", "", "servir métricas de Prometheus en esta dirección, p. ej. 127.0.0.1:9464 (env GEOVA_METRICS_ADDR)")
+	retainPackets := fs.Int("retain-packets", -1, "paquetes terminados que se conservan en memoria (0 = sin límite, por defecto 500)")
+	retainAge := fs.Duration("retain-age", -1, "cuánto tiempo simulado se conserva un paquete después de terminar (0 = sin límite, por defecto 1m)")
+	retainDead := fs.Int("retain-dead-letters", -1, "paquetes que esperan en dead letters (0 = sin límite, por defecto 100)")
+	speed := fs.Float64("speed", -1, "velocidad inicial de la simulación: 0 (pausa), 0.25, 0.5, 1, 2 o 4 (por defecto 1)")
+	headless := fs.Bool("headless", false, "correr sin ventana, sólo generación de tráfico y resúmenes por consola")
+	duration := fs.Duration("duration", 0, "headless: dejar de generar tras este tiempo (0 = sin límite)")
+	maxPackets := fs.Int("max-packets", 0, "headless: dejar de generar tras esta cantidad de paquetes (0 = sin límite)")
+	maxErrorRate := fs.Float64("max-error-rate", -1, "headless: salir con código 1 si la tasa de error supera esta fracción")
+	reportInterval := fs.Duration("report-interval", 0, "headless: cada cuánto imprimir el resumen (por defecto 5s)")
+	mock := fs.Bool("mock", false, "levantar la API mock en memoria y apuntar los sensores a ella")
+	mockAddr := fs.String("mock-addr", "", "dirección donde escucha la API mock (por defecto 127.0.0.1:8000)")
+	mockErrorRate := fs.Float64("mock-error-rate", -1, "fracción de respuestas con error HTTP en la API mock")
+	mockTimeoutRate := fs.Float64("mock-timeout-rate", -1, "fracción de peticiones que la API mock deja colgadas")
+	mockBroadcastLoss := fs.Float64("mock-broadcast-loss", -1, "fracción de lecturas aceptadas que la API mock no reenvía por WebSocket")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.applyEnv()
+
+	if *apiURL != "" {
+		cfg.Endpoints.SetBaseURL(*apiURL)
+	}
+	if *httpTimeout > 0 {
+		cfg.HTTP.TimeoutMs = int(httpTimeout.Milliseconds())
+	}
+	if *maxAttempts > 0 {
+		for kind, ep := range cfg.Endpoints {
+			ep.Retry.MaxAttempts = *maxAttempts
+			cfg.Endpoints[kind] = ep
+		}
+	}
+	if *projects != "" {
+		if err := cfg.parseProjects(*projects); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range captures {
+		kind, path, ok := strings.Cut(c, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("captura inválida %q, se esperaba 'sensor=archivo'", c)
+		}
+		if err := cfg.setCapture(simulation.SensorKind(kind), simulation.CaptureConfig{
+			Path:             path,
+			Loop:             *captureLoop,
+			RewriteTimestamp: *captureNow,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if *fleet > 0 {
+		cfg.Fleet.Size = *fleet
+	}
+	if *fleetJitter >= 0 {
+		cfg.Fleet.IntervalJitter = *fleetJitter
+	}
+	if cfg.Fleet.IntervalJitter < 0 || cfg.Fleet.IntervalJitter >= 1 {
+		return nil, fmt.Errorf("fleet-jitter %g fuera de rango, debe estar en [0, 1)", cfg.Fleet.IntervalJitter)
+	}
+	if err := cfg.validateProjects(); err != nil {
+		return nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			cfg.Seed, cfg.SeedSet = *seed, true
+		}
+	})
+	if *fixedTimestamps {
+		cfg.FixedTimestamps = true
+	}
+	if !cfg.SeedSet {
+		cfg.Seed = time.Now().UnixNano()
+	} else if cfg.Mock.Seed == 0 {
+		cfg.Mock.Seed = cfg.Seed
+	}
+	if *interval > 0 {
+		cfg.BatchInterval = *interval
+	}
+	if *rate > 0 {
+		cfg.Load.Rate = *rate
+	}
+	if *concurrency > 0 {
+		cfg.Load.Concurrency = *concurrency
+	}
+	if *rampUp > 0 {
+		cfg.Load.RampUpMs = int(rampUp.Milliseconds())
+	}
+	if *summary != "" {
+		cfg.Load.SummaryPath = *summary
+	}
+	if *brokerCapacity > 0 || *brokerConsumers > 0 || *brokerPrefetch > 0 {
+		if len(cfg.Broker.Queues) == 0 {
+			cfg.Broker = simulation.DefaultBrokerConfig()
+		}
+		for i := range cfg.Broker.Queues {
+			q := &cfg.Broker.Queues[i]
+			if *brokerCapacity > 0 {
+				q.Capacity = *brokerCapacity
+			}
+			if *brokerConsumers > 0 {
+				q.Consumers = *brokerConsumers
+			}
+			if *brokerPrefetch > 0 {
+				q.Prefetch = *brokerPrefetch
+			}
+		}
+	}
+	if *confirm {
+		cfg.Confirm.Enabled = true
+	}
+	if *confirmURL != "" {
+		cfg.Confirm.URL = *confirmURL
+	}
+	if *confirmTimeout > 0 {
+		cfg.Confirm.TimeoutMs = int(confirmTimeout.Milliseconds())
+	}
+	if *topology != "" {
+		t, err := simulation.LoadTopology(*topology)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Topology = t
+	}
+	if *metricsAddr != "" {
+		cfg.Metrics.Addr = *metricsAddr
+	}
+	if *retainPackets >= 0 {
+		cfg.Retention.MaxPackets = *retainPackets
+	}
+	if *retainAge >= 0 {
+		cfg.Retention.MaxAgeMs = int(retainAge.Milliseconds())
+	}
+	if *retainDead >= 0 {
+		cfg.Retention.MaxDeadLetters = *retainDead
+	}
+	if *speed >= 0 {
+		cfg.Speed = *speed
+	}
+	if err := simulation.ValidSpeed(cfg.Speed); err != nil {
+		return nil, err
+	}
+	if *replaySpeed <= 0 {
+		return nil, fmt.Errorf("-replay-speed debe ser positivo")
+	}
+	cfg.Session = Session{Record: *record, Replay: *replay, ReplaySpeed: *replaySpeed, Trace: *trace}
+	cfg.Headless.Enabled = *headless
+	cfg.Headless.Duration = *duration
+	cfg.Headless.MaxPackets = *maxPackets
+	if *maxErrorRate >= 0 {
+		cfg.Headless.MaxErrorRate = *maxErrorRate
+	}
+	if *reportInterval > 0 {
+		cfg.Headless.ReportInterval = *reportInterval
+	}
+	if cfg.Headless.Enabled && cfg.Speed == 0 {
+		// Sin ventana no hay tecla para salir de la pausa.
+		return nil, fmt.Errorf("-speed 0 no tiene sentido con -headless")
+	}
+	if *mock {
+		cfg.Mock.Enabled = true
+	}
+	if *mockAddr != "" {
+		cfg.Mock.Addr = *mockAddr
+	}
+	if *mockErrorRate >= 0 {
+		cfg.Mock.Default.ErrorRate = *mockErrorRate
+	}
+	if *mockTimeoutRate >= 0 {
+		cfg.Mock.Default.TimeoutRate = *mockTimeoutRate
+	}
+	if *mockBroadcastLoss >= 0 {
+		cfg.Mock.BroadcastLossRate = *mockBroadcastLoss
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("cabecera inválida %q, se esperaba 'Nombre: valor'", h)
+		}
+		cfg.Endpoints.SetHeader(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("no se pudo leer la configuración '%s': %w", path, err)
+	}
+
+	// El JSON se decodifica sobre los valores actuales para que los campos
+	// ausentes no los pisen. Los endpoints se fusionan campo por campo.
+	endpoints := c.Endpoints
+	c.Endpoints = nil
+	err = json.Unmarshal(data, c)
+	overrides := c.Endpoints
+	c.Endpoints = endpoints
+	if err != nil {
+		return fmt.Errorf("configuración inválida en '%s': %w", path, err)
+	}
+
+	// "seed": 0 también fija la semilla: se mira si la clave está.
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(data, &keys) == nil {
+		if _, ok := keys["seed"]; ok {
+			c.SeedSet = true
+		}
+	}
+
+	for kind := range overrides {
+		if _, ok := c.Endpoints[kind]; !ok {
+			return fmt.Errorf("sensor desconocido '%s' en '%s'", kind, path)
+		}
+	}
+	c.Endpoints.Merge(overrides)
+
+	var raw struct {
+		Projects []json.RawMessage `json:"projects"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("configuración inválida en '%s': %w", path, err)
+	}
+	for _, r := range raw.Projects {
+		p := c.newProject(0)
+		if err := json.Unmarshal(r, &p); err != nil {
+			return fmt.Errorf("proyecto inválido en '%s': %w", path, err)
+		}
+		c.Projects = append(c.Projects, p)
+	}
+	return nil
+}
+
+// newProject crea un proyecto con las secciones de sensores globales.
+func (c *Config) newProject(id int) simulation.ProjectConfig {
+	return simulation.ProjectConfig{
+		ID:       id,
+		Name:     fmt.Sprintf("Proyecto %d", id),
+		DeviceID: fmt.Sprintf("geova-%02d", id),
+		MPU:      c.MPU,
+		TFLuna:   c.TFLuna,
+		IMX:      c.IMX,
+		Captures: maps.Clone(c.Captures),
+	}
+}
+
+// setCapture fija la captura de un sensor en la sección global y en todos
+// los proyectos ya definidos.
+func (c *Config) setCapture(kind simulation.SensorKind, capture simulation.CaptureConfig) error {
+	if _, ok := c.Endpoints[kind]; !ok {
+		return fmt.Errorf("sensor desconocido '%s' en -capture", kind)
+	}
+	if c.Captures == nil {
+		c.Captures = make(map[simulation.SensorKind]simulation.CaptureConfig)
+	}
+	c.Captures[kind] = capture
+	for i := range c.Projects {
+		if c.Projects[i].Captures == nil {
+			c.Projects[i].Captures = make(map[simulation.SensorKind]simulation.CaptureConfig)
+		}
+		c.Projects[i].Captures[kind] = capture
+	}
+	return nil
+}
+
+// parseProjects interpreta "-projects 4,7,9".
+func (c *Config) parseProjects(list string) error {
+	c.Projects = nil
+	for _, field := range strings.Split(list, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || id <= 0 {
+			return fmt.Errorf("ID de proyecto inválido '%s' en -projects", field)
+		}
+		c.Projects = append(c.Projects, c.newProject(id))
+	}
+	return nil
+}
+
+func (c *Config) validateProjects() error {
+	if len(c.Projects) == 0 {
+		p := simulation.DefaultProject()
+		p.MPU, p.TFLuna, p.IMX = c.MPU, c.TFLuna, c.IMX
+		p.Captures = maps.Clone(c.Captures)
+		c.Projects = []simulation.ProjectConfig{p}
+	}
+	seen := make(map[int]bool)
+	for _, p := range c.Projects {
+		if p.ID <= 0 {
+			return fmt.Errorf("el proyecto '%s' necesita un id positivo", p.Name)
+		}
+		if seen[p.ID] {
+			return fmt.Errorf("proyecto %d repetido", p.ID)
+		}
+		seen[p.ID] = true
+		for _, err := range []error{p.MPU.Validate(), p.TFLuna.Validate(), p.IMX.Validate()} {
+			if err != nil {
+				return fmt.Errorf("proyecto %d: %w", p.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnv lee GEOVA_SEED, GEOVA_METRICS_ADDR, GEOVA_API_URL y
+// GEOVA_<SENSOR>_URL (p. ej. GEOVA_TFLUNA_URL).
+func (c *Config) applyEnv() {
+	if v := os.Getenv("GEOVA_SEED"); v != "" {
+		if seed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.Seed, c.SeedSet = seed, true
+		}
+	}
+	if v := os.Getenv("GEOVA_METRICS_ADDR"); v != "" {
+		c.Metrics.Addr = v
+	}
+	if v := os.Getenv("GEOVA_API_URL"); v != "" {
+		c.Endpoints.SetBaseURL(v)
+	}
+	for _, kind := range simulation.SensorKinds {
+		key := "GEOVA_" + strings.ToUpper(string(kind)) + "_URL"
+		if v := os.Getenv(key); v != "" {
+			c.Endpoints.Merge(simulation.EndpointRegistry{kind: {BaseURL: v}})
+		}
+	}
+}
@@ -4,29 +4,33 @@ const (
 	tripodeX = 80.0
 	tripodeY = 200.0
 
-	iconPythonX    = 250.0
-	iconPythonY    = 200.0
-	iconRabbitX    = 400.0
-	iconRabbitY    = 200.0
-	iconWebsocketX = 550.0
-	iconWebsocketY = 200.0
-
-	monitorX = 620.0
-	monitorY = 180.0
-
 	tiltMeterX = 100.0
 	tiltMeterY = 50.0
 
 	dashboardX = 50.0
 	dashboardY = 450.0
 
-	packetSpeed     = 3.0
-	processingDelay = 30
+	latencyOverlayX = 600.0
+	latencyOverlayY = 40.0
+
+	inspectorX = 560.0
+	inspectorY = 460.0
+	// inspectorHistory es cuántos cambios de estado muestra el inspector.
+	inspectorHistory = 3
+
+	// Zona de dead letters bajo el monitor; la grilla donde se forman los
+	// paquetes la arma pipeline con la misma esquina.
+	deadLetterX = 690.0
+	deadLetterY = 392.0
+	deadLetterW = 190.0
+	deadLetterH = 64.0
 
 	tripodeFrameWidth  = 128
 	tripodeFrameHeight = 128
 	tripodeFrameCount  = 7
 
+	stageBoxSize = 64
+
 	monitorFrameWidth  = 256
 	monitorFrameHeight = 192
 	monitorFrameCount  = 8
@@ -2,35 +2,37 @@ package game
 
 import (
 	"geova-simulation/assets"
-	"geova-simulation/state"
+	"geova-simulation/pipeline"
 	"image"
 )
 
+// Game dibuja el pipeline y traduce la entrada del usuario; la FSM de los
+// paquetes la avanza el Pipeline embebido.
 type Game struct {
+	*pipeline.Pipeline
 	Assets *assets.Assets
-	State  *state.VisualState
 
 	BotonRect      image.Rectangle
 	isBotonPressed bool
 
-	animPacketCounter int
-	animIconCounter   int
+	// showLatency muestra el panel de latencias (tecla L).
+	showLatency bool
+	// inspectedID es el paquete elegido con click para el inspector.
+	inspectedID string
 }
 
-func NewGame(assets *assets.Assets, state *state.VisualState, btnRect image.Rectangle) *Game {
+func NewGame(assets *assets.Assets, pl *pipeline.Pipeline, btnRect image.Rectangle) *Game {
 	return &Game{
-		Assets:    assets,
-		State:     state,
-		BotonRect: btnRect,
+		Pipeline:    pl,
+		Assets:      assets,
+		BotonRect:   btnRect,
+		showLatency: pl.Runner != nil && pl.Runner.Load.Enabled(),
 	}
 }
 
 func (g *Game) Update() error {
-	g.animPacketCounter = (g.animPacketCounter + 1) % 360
-	g.animIconCounter = (g.animIconCounter + 1) % 360
-
 	g.handleInput()
-	g.updatePacketFSM()
+	g.Step()
 
 	return nil
 }
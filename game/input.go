@@ -2,11 +2,8 @@ package game
 
 import (
 	"fmt"
-	"geova-simulation/simulation"
 	"geova-simulation/state"
 	"image"
-	"image/color"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -16,6 +13,29 @@ func (g *Game) handleInput() {
 	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
 		ebiten.SetFullscreen(!ebiten.IsFullscreen())
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.showLatency = !g.showLatency
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) && len(g.State.Projects) > 0 {
+		g.State.SelectedProject = (g.State.SelectedProject + 1) % len(g.State.Projects)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		fmt.Printf("[RELOJ] %s\n", speedLabel(g.Clock.Toggle()))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract) {
+		fmt.Printf("[RELOJ] %s\n", speedLabel(g.Clock.Slower()))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd) {
+		fmt.Printf("[RELOJ] %s\n", speedLabel(g.Clock.Faster()))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyPeriod) {
+		g.Clock.Step()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		if n := g.RedriveAll(); n > 0 {
+			fmt.Printf("[DLQ] %d paquetes reenviados\n", n)
+		}
+	}
 
 	x, y := ebiten.CursorPosition()
 	clickPoint := image.Pt(x, y)
@@ -30,80 +50,66 @@ func (g *Game) handleInput() {
 	if ebiten.IsKeyPressed(ebiten.KeyRight) && g.State.CurrentTilt < 15.0 {
 		g.State.CurrentTilt += 0.5
 	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) && g.State.CurrentPitch > -15.0 {
+		g.State.CurrentPitch -= 0.5
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyUp) && g.State.CurrentPitch < 15.0 {
+		g.State.CurrentPitch += 0.5
+	}
 
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 		if g.BotonRect.Bounds().Canon().Overlaps(
 			image.Rectangle{Min: clickPoint, Max: clickPoint.Add(image.Pt(1, 1))},
 		) {
 			g.toggleSimulation()
+		} else {
+			g.inspectedID = g.packetAt(x, y)
+			g.redriveClicked()
 		}
 	}
 }
 
-func (g *Game) toggleSimulation() {
+// packetAt es el ID del paquete activo bajo el cursor ("" si no hay).
+func (g *Game) packetAt(x, y int) string {
 	g.State.Mutex.Lock()
-	if g.State.SimulacionIniciada {
-		if g.State.StopChan != nil {
-			close(g.State.StopChan)
-			g.State.StopChan = nil
+	defer g.State.Mutex.Unlock()
+	for id, p := range g.State.Packets {
+		if p.Active && image.Pt(x, y).In(image.Rect(int(p.X), int(p.Y), int(p.X)+32, int(p.Y)+32)) {
+			return id
 		}
-		g.State.SimulacionIniciada = false
-		g.State.Mutex.Unlock()
-		fmt.Println("[SIMULACIÓN] Detenida")
-		return
 	}
-
-	g.State.Packets = make(map[string]*state.PacketState)
-	g.State.DisplayDistancia = 0
-	g.State.DisplayNitidez = 0
-	g.State.DisplayRoll = 0
-	g.State.SimulacionIniciada = true
-	g.State.PacketID = 0
-	g.State.StopChan = make(chan struct{})
-	stopChan := g.State.StopChan
-	g.State.Mutex.Unlock()
-
-	fmt.Println("[SIMULACIÓN] Iniciada - Click de nuevo para detener")
-
-	go g.runContinuousSimulation(stopChan)
+	return ""
 }
 
-func (g *Game) runContinuousSimulation(stopChan chan struct{}) {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	g.sendBatchRequests()
-
-	for {
-		select {
-		case <-stopChan:
-			return
-		case <-ticker.C:
-			g.sendBatchRequests()
-		}
+// redriveClicked reenvía el paquete elegido si está en dead letters y pasa
+// el inspector al reenvío.
+func (g *Game) redriveClicked() {
+	if g.inspectedID == "" {
+		return
 	}
-}
-
-func (g *Game) sendBatchRequests() {
 	g.State.Mutex.Lock()
-	tilt := g.State.CurrentTilt
-	g.State.PacketID++
-	id := g.State.PacketID
+	p, ok := g.State.Packets[g.inspectedID]
+	dead := ok && p.Status == state.Error && p.Active
 	g.State.Mutex.Unlock()
+	if !dead {
+		return
+	}
+	packet, err := g.Redrive(g.inspectedID)
+	if err != nil {
+		fmt.Printf("[DLQ] %v\n", err)
+		return
+	}
+	g.inspectedID = packet.ID
+}
 
-	go simulation.SendPOSTRequest(
-		"http://localhost:8000/tfluna/sensor",
-		simulation.GenerateRandomTFLunaData(),
-		fmt.Sprintf("tfluna_%d", id), g.State, 180.0, color.RGBA{R: 255, G: 50, B: 50, A: 255},
-	)
-	go simulation.SendPOSTRequest(
-		"http://localhost:8000/mpu/sensor",
-		simulation.GenerateRandomMPUData(tilt),
-		fmt.Sprintf("mpu_%d", id), g.State, 200.0, color.RGBA{R: 50, G: 150, B: 255, A: 255},
-	)
-	go simulation.SendPOSTRequest(
-		"http://localhost:8000/imx477/sensor",
-		simulation.GenerateRandomIMXData(),
-		fmt.Sprintf("imx_%d", id), g.State, 220.0, color.RGBA{R: 50, G: 255, B: 50, A: 255},
-	)
+func (g *Game) toggleSimulation() {
+	if g.Stop() {
+		fmt.Println("[SIMULACIÓN] Detenida")
+		if err := g.Runner.WriteSummary(); err != nil {
+			fmt.Printf("[CARGA] %v\n", err)
+		}
+		return
+	}
+	g.Start()
+	fmt.Println("[SIMULACIÓN] Iniciada - Click de nuevo para detener")
 }
@@ -2,12 +2,16 @@ package game
 
 import (
 	"fmt"
+	"geova-simulation/simulation"
 	"geova-simulation/state"
 	"image"
 	"image/color"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 func (g *Game) Draw(screen *ebiten.Image) {
@@ -15,10 +19,15 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	g.drawTripode(screen)
 	g.drawTiltMeter(screen)
 	g.drawIcons(screen)
+	g.drawDeadLetters(screen)
 	g.drawPackets(screen)
 	g.drawButton(screen)
 	g.drawDashboard(screen)
-	ebitenutil.DebugPrintAt(screen, "Controles:  Flechas <- -> para inclinar ANTES de crear  |  Click en CREAR  |  F11 pantalla completa", 10, 10)
+	if g.showLatency {
+		g.drawLatencyOverlay(screen)
+	}
+	g.drawInspector(screen)
+	ebitenutil.DebugPrintAt(screen, "Controles:  Flechas <- -> roll, ^ v pitch  |  Click en CREAR  |  Click en paquete inspecciona (en dead letters lo reenvía)  |  R reenvía todos  |  Espacio pausa, - + velocidad, . un tick  |  L latencias  |  F11 pantalla completa", 10, 10)
 }
 
 func (g *Game) drawBackground(screen *ebiten.Image) {
@@ -38,14 +47,38 @@ func (g *Game) drawBackground(screen *ebiten.Image) {
 }
 
 func (g *Game) drawTripode(screen *ebiten.Image) {
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(tripodeX, tripodeY)
+	g.State.Mutex.Lock()
+	devices := append([]state.DeviceInfo(nil), g.State.Devices...)
+	g.State.Mutex.Unlock()
+
+	if len(devices) == 0 {
+		devices = []state.DeviceInfo{{Manual: true, LaneY: tripodeY, Scale: 1}}
+	}
+
+	for _, d := range devices {
+		tilt := d.Tilt
+		if d.Manual {
+			tilt = g.State.CurrentTilt
+		}
+
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(d.Scale, d.Scale)
+		op.GeoM.Translate(tripodeX, d.LaneY)
 
-	frameIndex := g.getTripodeFrame(g.State.CurrentTilt)
-	sx := frameIndex * tripodeFrameWidth
-	rect := image.Rect(sx, 0, sx+tripodeFrameWidth, tripodeFrameHeight)
+		frameIndex := g.getTripodeFrame(tilt)
+		sx := frameIndex * tripodeFrameWidth
+		rect := image.Rect(sx, 0, sx+tripodeFrameWidth, tripodeFrameHeight)
 
-	screen.DrawImage(g.Assets.UITiltMeter.SubImage(rect).(*ebiten.Image), op)
+		screen.DrawImage(g.Assets.UITiltMeter.SubImage(rect).(*ebiten.Image), op)
+
+		if len(devices) > 1 {
+			label := fmt.Sprintf("%s %+.0f°", d.ID, tilt)
+			if d.Manual {
+				label += " *"
+			}
+			ebitenutil.DebugPrintAt(screen, label, 5, int(d.LaneY+tripodeFrameHeight*d.Scale/2)-8)
+		}
+	}
 }
 
 func (g *Game) getTripodeFrame(tilt float64) int {
@@ -71,6 +104,9 @@ func (g *Game) drawTiltMeter(screen *ebiten.Image) {
 	ebitenutil.DebugPrintAt(screen,
 		fmt.Sprintf("Inclinación Actual: %.1f°", g.State.CurrentTilt),
 		int(tiltMeterX), int(tiltMeterY))
+	ebitenutil.DebugPrintAt(screen,
+		fmt.Sprintf("Pitch: %.1f°", g.State.CurrentPitch),
+		int(tiltMeterX), int(tiltMeterY)+15)
 
 	meterX := int(tiltMeterX) + 200
 	meterY := int(tiltMeterY)
@@ -103,12 +139,12 @@ func (g *Game) drawButton(screen *ebiten.Image) {
 	}
 }
 
-func (g *Game) drawMonitor(screen *ebiten.Image) {
+func (g *Game) drawMonitor(screen *ebiten.Image, x, y float64) {
 	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(monitorX, monitorY)
+	op.GeoM.Translate(x, y)
 
 	if g.State.SimulacionIniciada {
-		frameIndex := (g.animIconCounter / monitorAnimSpeed) % monitorFrameCount
+		frameIndex := (g.IconFrame() / monitorAnimSpeed) % monitorFrameCount
 		sx := frameIndex * monitorFrameWidth
 		rect := image.Rect(sx, 0, sx+monitorFrameWidth, monitorFrameHeight)
 		screen.DrawImage(g.Assets.MonitorAnim.SubImage(rect).(*ebiten.Image), op)
@@ -117,26 +153,135 @@ func (g *Game) drawMonitor(screen *ebiten.Image) {
 	}
 }
 
+// drawIcons dibuja cada etapa de la topología con su sprite (o una caja con
+// su nombre si no tiene uno conocido).
 func (g *Game) drawIcons(screen *ebiten.Image) {
-	g.drawIcon(screen, g.Assets.IconPythonIdle, g.Assets.IconPythonActiveAnim,
-		g.State.PythonAPITimer, iconPythonX, iconPythonY)
-	g.drawIcon(screen, g.Assets.IconRabbitIdle, g.Assets.IconRabbitActiveAnim,
-		g.State.RabbitMQTimer, iconRabbitX, iconRabbitY)
-	g.drawIcon(screen, g.Assets.IconWebsocketIdle, g.Assets.IconWebsocketActiveAnim,
-		g.State.WebsocketAPITimer, iconWebsocketX, iconWebsocketY)
+	g.State.Mutex.Lock()
+	activity := make(map[string]time.Duration, len(g.State.StageActivity))
+	for id, left := range g.State.StageActivity {
+		activity[id] = left
+	}
+	waiting := make(map[string]int, len(g.State.StageLines))
+	for id, line := range g.State.StageLines {
+		waiting[id] = len(line)
+	}
+	g.State.Mutex.Unlock()
 
-	g.drawMonitor(screen)
+	for _, s := range g.Topology.Stages {
+		if s.Sprite == "monitor" {
+			g.drawMonitor(screen, s.X, s.Y)
+		} else if idle, anim := g.stageSprites(s.Sprite); idle != nil {
+			g.drawIcon(screen, idle, anim, activity[s.ID], s.X, s.Y)
+		} else {
+			g.drawStageBox(screen, s, activity[s.ID] > 0)
+		}
+
+		if s.Kind == simulation.StageQueue {
+			g.drawQueueDepth(screen, s)
+		}
+		g.drawStageLoad(screen, s, waiting[s.ID])
+		// El estado del WebSocket va bajo la etapa que entrega al frontend.
+		if g.Confirmer != nil && g.Topology.Feeds(s.ID, simulation.StageFrontend) {
+			status := "WS: --"
+			if g.Confirmer.Connected() {
+				status = "WS: ok"
+			}
+			ebitenutil.DebugPrintAt(screen, status, int(s.X), int(s.Y)+70)
+			ebitenutil.DebugPrintAt(screen, fmt.Sprintf("msgs: %d", g.Confirmer.Received()),
+				int(s.X), int(s.Y)+84)
+		}
+	}
+}
+
+// stageSprites retorna los iconos (inactivo, animado) de un sprite de la
+// topología, o nil si no hay uno con ese nombre.
+func (g *Game) stageSprites(name string) (idle, anim *ebiten.Image) {
+	switch name {
+	case "python":
+		return g.Assets.IconPythonIdle, g.Assets.IconPythonActiveAnim
+	case "rabbitmq":
+		return g.Assets.IconRabbitIdle, g.Assets.IconRabbitActiveAnim
+	case "websocket":
+		return g.Assets.IconWebsocketIdle, g.Assets.IconWebsocketActiveAnim
+	}
+	return nil, nil
+}
+
+// drawStageBox dibuja una etapa sin sprite del mismo tamaño que los iconos.
+func (g *Game) drawStageBox(screen *ebiten.Image, s simulation.StageConfig, active bool) {
+	fill := color.RGBA{R: 40, G: 40, B: 60, A: 255}
+	if active && (g.IconFrame()/15)%2 == 0 {
+		fill = color.RGBA{R: 70, G: 90, B: 140, A: 255}
+	}
+	vector.FillRect(screen, float32(s.X), float32(s.Y), stageBoxSize, stageBoxSize, fill, false)
+	vector.StrokeRect(screen, float32(s.X), float32(s.Y), stageBoxSize, stageBoxSize, 2, color.White, false)
+	ebitenutil.DebugPrintAt(screen, s.Label, int(s.X)+4, int(s.Y)+stageBoxSize/2-8)
+}
+
+// drawQueueDepth lista bajo una etapa de cola los mensajes listos, sin ack
+// y la capacidad de cada una de sus colas del broker.
+func (g *Game) drawQueueDepth(screen *ebiten.Image, s simulation.StageConfig) {
+	y := int(s.Y) + 70
+	for _, q := range g.Broker.Stats(s.ID) {
+		capacity := "∞"
+		if q.Capacity > 0 {
+			capacity = fmt.Sprintf("%d", q.Capacity)
+		}
+		line := fmt.Sprintf("%s %d/%s +%d", strings.TrimPrefix(q.Name, "geova."), q.Ready, capacity, q.Unacked)
+		if q.Capacity > 0 && q.Ready >= q.Capacity {
+			line += " LLENA"
+		}
+		ebitenutil.DebugPrintAt(screen, line, int(s.X)-10, y)
+		y += 14
+	}
+}
+
+// drawStageLoad muestra sobre la etapa los lugares ocupados y el largo de su
+// fila de espera.
+func (g *Game) drawStageLoad(screen *ebiten.Image, s simulation.StageConfig, waiting int) {
+	if s.Kind == simulation.StageFrontend || (s.Capacity == 0 && s.QueueLimit == 0 && waiting == 0) {
+		return
+	}
+	line := fmt.Sprintf("fila %d", waiting)
+	if s.QueueLimit > 0 {
+		line = fmt.Sprintf("fila %d/%d", waiting, s.QueueLimit)
+	}
+	if s.Kind != simulation.StageQueue {
+		capacity := "∞"
+		if s.Capacity > 0 {
+			capacity = fmt.Sprintf("%d", s.Capacity)
+		}
+		line = fmt.Sprintf("%d/%s %s", g.Occupied(s.ID), capacity, line)
+	}
+	if s.QueueLimit > 0 && waiting >= s.QueueLimit {
+		line += " LLENA"
+	}
+	ebitenutil.DebugPrintAt(screen, line, int(s.X)-10, int(s.Y)-16)
+}
+
+// drawDeadLetters dibuja la zona donde esperan los paquetes en Error.
+func (g *Game) drawDeadLetters(screen *ebiten.Image) {
+	g.State.Mutex.Lock()
+	n := len(g.State.DeadLetters)
+	g.State.Mutex.Unlock()
+
+	border := color.RGBA{R: 120, G: 120, B: 120, A: 255}
+	if n > 0 {
+		border = color.RGBA{R: 255, G: 80, B: 80, A: 255}
+	}
+	vector.StrokeRect(screen, deadLetterX, deadLetterY, deadLetterW, deadLetterH, 1, border, false)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("✗ Dead letters: %d", n), int(deadLetterX), int(deadLetterY)-15)
 }
 
 func (g *Game) drawIcon(screen *ebiten.Image, idle *ebiten.Image, anim *ebiten.Image,
-	timer int, x, y float64) {
+	timer time.Duration, x, y float64) {
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(x, y)
 
 	if timer > 0 {
 		frameWidth := 64
 		frameCount := 6
-		frameIndex := (g.animIconCounter / 6) % frameCount
+		frameIndex := (g.IconFrame() / 6) % frameCount
 		sx := frameIndex * frameWidth
 		rect := image.Rect(sx, 0, sx+frameWidth, 64)
 		screen.DrawImage(anim.SubImage(rect).(*ebiten.Image), op)
@@ -151,7 +296,7 @@ func (g *Game) drawPackets(screen *ebiten.Image) {
 
 	frameWidth := 32
 	frameCount := 6
-	frameIndex := (g.animPacketCounter / 6) % frameCount
+	frameIndex := (g.PacketFrame() / 6) % frameCount
 	sx := frameIndex * frameWidth
 	rect := image.Rect(sx, 0, sx+frameWidth, 32)
 	packetFrame := g.Assets.DataPacketAnim.SubImage(rect).(*ebiten.Image)
@@ -175,19 +320,40 @@ func (g *Game) drawPackets(screen *ebiten.Image) {
 		labelY := int(packet.Y) - 10
 
 		var label string
-		switch packet.ID {
-		case "tfluna":
+		switch simulation.SensorKind(packet.Sensor) {
+		case simulation.SensorTFLuna:
 			label = "TFL"
-		case "mpu":
+		case simulation.SensorMPU:
 			label = "MPU"
-		case "imx":
+		case simulation.SensorIMX:
 			label = "IMX"
 		}
+		if len(g.State.Projects) > 1 {
+			label = fmt.Sprintf("%s p%d", label, packet.ProjectID)
+		}
 
 		ebitenutil.DebugPrintAt(screen, label, labelX, labelY)
 
-		if packet.Status == state.Error {
-			ebitenutil.DebugPrintAt(screen, "✗ ERROR", int(packet.X)-10, int(packet.Y)+25)
+		if packet.Status == state.Lost {
+			ebitenutil.DebugPrintAt(screen, "✗ PERDIDO", int(packet.X)-15, int(packet.Y)+25)
+		}
+
+		if packet.Status == state.AwaitingEcho {
+			ebitenutil.DebugPrintAt(screen, "esperando WS", int(packet.X)-20, int(packet.Y)+25)
+		}
+
+		if packet.Status == state.Cancelled {
+			ebitenutil.DebugPrintAt(screen, "CANCELADO", int(packet.X)-15, int(packet.Y)+25)
+		}
+
+		if packet.Status == state.Retrying {
+			ebitenutil.DebugPrintAt(screen,
+				fmt.Sprintf("REINTENTO %d/%d", packet.Attempt+1, packet.MaxAttempts),
+				int(packet.X)-20, int(packet.Y)+25)
+		} else if packet.Attempt > 1 && packet.Status == state.SendingToAPI {
+			ebitenutil.DebugPrintAt(screen,
+				fmt.Sprintf("intento %d/%d", packet.Attempt, packet.MaxAttempts),
+				int(packet.X)-20, int(packet.Y)+25)
 		}
 	}
 }
@@ -195,28 +361,36 @@ func (g *Game) drawPackets(screen *ebiten.Image) {
 func (g *Game) drawDashboard(screen *ebiten.Image) {
 	y := int(dashboardY)
 
-	ebitenutil.DebugPrintAt(screen, "--- Dashboard de Resultados ---", int(dashboardX), y)
+	project := g.State.SelectedProjectInfo()
+	readings := g.State.ReadingsFor(project.ID)
+
+	header := "--- Dashboard de Resultados ---"
+	if len(g.State.Projects) > 1 {
+		header = fmt.Sprintf("--- Dashboard: Proyecto %d %s [%d/%d] (Tab cambia) ---",
+			project.ID, project.Name, g.State.SelectedProject+1, len(g.State.Projects))
+	}
+	ebitenutil.DebugPrintAt(screen, header, int(dashboardX), y)
 	y += 20
 
-	distText := fmt.Sprintf("  Distancia (TFLuna): %.2f m", g.State.DisplayDistancia)
-	if g.State.DisplayDistancia == 0 {
+	distText := fmt.Sprintf("  Distancia (TFLuna): %.2f m", readings.DisplayDistancia)
+	if readings.DisplayDistancia == 0 {
 		distText = "  Distancia (TFLuna): --"
 	}
 	ebitenutil.DebugPrintAt(screen, distText, int(dashboardX), y)
 	y += 25
 
 	nitText := "  Nitidez (IMX477):"
-	if g.State.DisplayNitidez == 0 {
+	if readings.DisplayNitidez == 0 {
 		nitText = "  Nitidez (IMX477): --"
 	}
 	ebitenutil.DebugPrintAt(screen, nitText, int(dashboardX), y)
 
-	if g.State.DisplayNitidez > 0 {
+	if readings.DisplayNitidez > 0 {
 		opBarBG := &ebiten.DrawImageOptions{}
 		opBarBG.GeoM.Translate(dashboardX+180, float64(y))
 		screen.DrawImage(g.Assets.UIProgressBG, opBarBG)
 
-		normalizedNitidez := (g.State.DisplayNitidez - 4.0) / 2.0
+		normalizedNitidez := (readings.DisplayNitidez - 4.0) / 2.0
 		if normalizedNitidez < 0 {
 			normalizedNitidez = 0
 		}
@@ -230,14 +404,14 @@ func (g *Game) drawDashboard(screen *ebiten.Image) {
 		screen.DrawImage(g.Assets.UIProgressFill, opBarFill)
 
 		ebitenutil.DebugPrintAt(screen,
-			fmt.Sprintf("%.2f", g.State.DisplayNitidez),
+			fmt.Sprintf("%.2f", readings.DisplayNitidez),
 			int(dashboardX)+330, y)
 	}
 
 	y += 25
 
-	rollText := fmt.Sprintf("  Inclinacion Roll (MPU): %.1f°", g.State.DisplayRoll)
-	if g.State.DisplayRoll == 0 {
+	rollText := fmt.Sprintf("  Inclinacion Roll (MPU): %.1f°", readings.DisplayRoll)
+	if readings.DisplayRoll == 0 {
 		rollText = "  Inclinacion Roll (MPU): --"
 	}
 	ebitenutil.DebugPrintAt(screen, rollText, int(dashboardX), y)
@@ -249,4 +423,112 @@ func (g *Game) drawDashboard(screen *ebiten.Image) {
 	} else {
 		ebitenutil.DebugPrintAt(screen, ">> Listo para nueva simulacion", int(dashboardX), y)
 	}
+
+	g.State.Mutex.Lock()
+	inMemory, retired := len(g.State.Packets), g.State.Retired.Packets
+	g.State.Mutex.Unlock()
+	y += 15
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("   %d paquetes en memoria, %d retirados", inMemory, retired),
+		int(dashboardX), y)
+	y += 15
+	ebitenutil.DebugPrintAt(screen, "   "+speedLabel(g.Clock.Speed()), int(dashboardX), y)
+}
+
+// speedLabel describe la velocidad del reloj para el dashboard y los logs.
+func speedLabel(speed float64) string {
+	if speed == 0 {
+		return "Velocidad: PAUSA (. avanza un tick)"
+	}
+	return fmt.Sprintf("Velocidad: %g×", speed)
+}
+
+// drawLatencyOverlay muestra los percentiles por endpoint y, en modo de
+// carga, el ritmo objetivo y la ocupación del límite de concurrencia.
+func (g *Game) drawLatencyOverlay(screen *ebiten.Image) {
+	x, y := int(latencyOverlayX), int(latencyOverlayY)
+
+	g.State.Mutex.Lock()
+	load := g.State.Load
+	g.State.Mutex.Unlock()
+
+	if load.Enabled {
+		line := fmt.Sprintf("Carga: %.1f req/s x sensor  en curso %d", load.TargetRate, load.InFlight)
+		if load.Waiting > 0 {
+			line += fmt.Sprintf("  esperando %d", load.Waiting)
+		}
+		ebitenutil.DebugPrintAt(screen, line, x, y)
+		y += 15
+	}
+
+	ebitenutil.DebugPrintAt(screen, "sensor      n     p50    p95    p99    max", x, y)
+	y += 15
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	for _, s := range g.State.Latency.Summaries() {
+		ebitenutil.DebugPrintAt(screen,
+			fmt.Sprintf("%-7s %6d %6.0f %6.0f %6.0f %6.0f", s.Name, s.Count, ms(s.P50), ms(s.P95), ms(s.P99), ms(s.Max)),
+			x, y)
+		y += 15
+	}
+}
+
+// drawInspector muestra el paquete elegido con click, con el ID de
+// correlación que viaja en la cabecera X-Request-ID.
+func (g *Game) drawInspector(screen *ebiten.Image) {
+	if g.inspectedID == "" {
+		return
+	}
+	g.State.Mutex.Lock()
+	p, ok := g.State.Packets[g.inspectedID]
+	if !ok {
+		g.State.Mutex.Unlock()
+		return
+	}
+	lines := []string{
+		"Paquete " + p.ID,
+		"rid: " + p.CorrelationID,
+		fmt.Sprintf("%s  proyecto %d  %s", p.Sensor, p.ProjectID, p.DeviceID),
+		fmt.Sprintf("estado: %s  intento %d/%d", p.Status, p.Attempt, p.MaxAttempts),
+	}
+	if p.LastStatusCode != 0 {
+		lines = append(lines, fmt.Sprintf("último HTTP: %d", p.LastStatusCode))
+	}
+	if p.LastError != "" {
+		lines = append(lines, "error: "+truncate(p.LastError, 45))
+	}
+	if p.FailureReason != "" {
+		lines = append(lines, "fallo: "+truncate(p.FailureReason, 45))
+	}
+	if p.RetryOf != "" {
+		lines = append(lines, "reenvío de "+p.RetryOf)
+	}
+	if p.RedrivenAs != "" {
+		lines = append(lines, "reenviado como "+p.RedrivenAs)
+	}
+	// Los últimos cambios de estado, con el tiempo desde el anterior.
+	history := p.History
+	if len(history) > inspectorHistory {
+		history = history[len(history)-inspectorHistory:]
+	}
+	for i, h := range history {
+		step := ""
+		if i > 0 {
+			step = fmt.Sprintf(" +%v", h.At.Sub(history[i-1].At).Round(time.Millisecond))
+		}
+		lines = append(lines, fmt.Sprintf("  → %s %s%s", h.To, h.Stage, step))
+	}
+	g.State.Mutex.Unlock()
+
+	y := int(inspectorY)
+	for _, line := range lines {
+		ebitenutil.DebugPrintAt(screen, line, int(inspectorX), y)
+		y += 15
+	}
+}
+
+// truncate corta s a n bytes, con "..." si lo cortó.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
 }
@@ -0,0 +1,160 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"geova-simulation/pipeline"
+	"geova-simulation/state"
+	"time"
+)
+
+// Options controla una corrida sin ventana.
+type Options struct {
+	Duration       time.Duration // 0 = hasta MaxPackets o Ctrl+C
+	MaxPackets     int           // 0 = sin límite
+	MaxErrorRate   float64       // código de salida 1 si se supera
+	ReportInterval time.Duration // cada cuánto imprimir el resumen
+	TickRate       int           // ticks de FSM por segundo
+	DrainTimeout   time.Duration // espera a paquetes en curso al terminar
+}
+
+// DefaultOptions imita el ritmo de Ebitengine (60 TPS) y reporta cada 5s.
+func DefaultOptions() Options {
+	return Options{
+		MaxErrorRate:   1,
+		ReportInterval: 5 * time.Second,
+		TickRate:       pipeline.TickRate,
+		DrainTimeout:   15 * time.Second,
+	}
+}
+
+// Run ejecuta el mismo loop de batches que la ventana, avanzando la FSM con
+// un ticker fijo y sin dibujar. Retorna el código de salida del proceso.
+func Run(ctx context.Context, pl *pipeline.Pipeline, opts Options) int {
+	if opts.TickRate <= 0 {
+		opts.TickRate = pipeline.TickRate
+	}
+	if opts.ReportInterval <= 0 {
+		opts.ReportInterval = 5 * time.Second
+	}
+
+	// ctx sólo se cancela al final (o con Ctrl+C) para no abortar los
+	// paquetes que siguen en vuelo cuando se deja de generar.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pl.State.Mutex.Lock()
+	pl.State.SimulacionIniciada = true
+	pl.State.Cancel = cancel
+	pl.State.Mutex.Unlock()
+
+	pl.Runner.MaxPackets = opts.MaxPackets
+	pl.Runner.Duration = opts.Duration
+	genDone := make(chan struct{})
+	go func() {
+		pl.Runner.Run(ctx)
+		close(genDone)
+	}()
+
+	fmt.Printf("[HEADLESS] Iniciado (duración=%v, paquetes=%d, error máx=%.0f%%)\n",
+		opts.Duration, opts.MaxPackets, opts.MaxErrorRate*100)
+
+	tick := time.NewTicker(time.Second / time.Duration(opts.TickRate))
+	defer tick.Stop()
+	report := time.NewTicker(opts.ReportInterval)
+	defer report.Stop()
+
+	start := time.Now()
+	var last state.Stats
+	lastReport := start
+	var drainDeadline <-chan time.Time
+
+	for {
+		select {
+		case <-tick.C:
+			pl.Step()
+
+		case <-report.C:
+			now := time.Now()
+			stats := snapshot(pl.State)
+			printReport(stats, last, now.Sub(lastReport), now.Sub(start))
+			last, lastReport = stats, now
+
+		case <-genDone:
+			genDone = nil
+			fmt.Println("[HEADLESS] Generación terminada, esperando paquetes en curso...")
+			drainDeadline = time.After(opts.DrainTimeout)
+
+		case <-drainDeadline:
+			fmt.Println("[HEADLESS] Tiempo de espera agotado, cancelando paquetes pendientes")
+			cancel()
+			drainDeadline = nil
+		}
+
+		if genDone == nil && pending(pl.State) == 0 {
+			break
+		}
+	}
+
+	stats := snapshot(pl.State)
+	elapsed := time.Since(start)
+	fmt.Println("[HEADLESS] ===== Resumen final =====")
+	printReport(stats, state.Stats{}, elapsed, elapsed)
+	for _, l := range pl.State.Latency.Summaries() {
+		fmt.Printf("[HEADLESS] %-7s n=%d p50=%v p95=%v p99=%v max=%v\n", l.Name, l.Count,
+			l.P50.Round(time.Millisecond), l.P95.Round(time.Millisecond),
+			l.P99.Round(time.Millisecond), l.Max.Round(time.Millisecond))
+	}
+	printRetention(pl.State)
+	if err := pl.Runner.WriteSummary(); err != nil {
+		fmt.Printf("[HEADLESS] %v\n", err)
+	}
+
+	if stats.ErrorRate() > opts.MaxErrorRate {
+		fmt.Printf("[HEADLESS] ✗ Tasa de error %.1f%% supera el umbral %.1f%%\n",
+			stats.ErrorRate()*100, opts.MaxErrorRate*100)
+		return 1
+	}
+	return 0
+}
+
+func snapshot(vs *state.VisualState) state.Stats {
+	vs.Mutex.Lock()
+	defer vs.Mutex.Unlock()
+	return vs.Stats
+}
+
+func pending(vs *state.VisualState) int {
+	vs.Mutex.Lock()
+	defer vs.Mutex.Unlock()
+	n := 0
+	for _, p := range vs.Packets {
+		if !p.Finished() {
+			n++
+		}
+	}
+	return n
+}
+
+// printRetention resume los paquetes que la retención sacó de memoria.
+func printRetention(vs *state.VisualState) {
+	vs.Mutex.Lock()
+	defer vs.Mutex.Unlock()
+	r := vs.Retired
+	if r.Packets == 0 {
+		return
+	}
+	fmt.Printf("[HEADLESS] Retención: %d paquetes retirados (Done=%d Error=%d Lost=%d Dropped=%d Cancelled=%d), %d en memoria\n",
+		r.Packets, r.ByStatus[state.Done], r.ByStatus[state.Error], r.ByStatus[state.Lost],
+		r.ByStatus[state.Dropped], r.ByStatus[state.Cancelled], len(vs.Packets))
+	l := r.Lifetime.Summary("vida")
+	fmt.Printf("[HEADLESS] Vida de los retirados: p50=%v p95=%v max=%v\n",
+		l.P50.Round(time.Millisecond), l.P95.Round(time.Millisecond), l.Max.Round(time.Millisecond))
+}
+
+func printReport(cur, prev state.Stats, window, total time.Duration) {
+	rate := float64(cur.Succeeded+cur.Failed-prev.Succeeded-prev.Failed) / window.Seconds()
+	fmt.Printf("[HEADLESS] t=%-6v creados=%d ok=%d error=%d reintentos=%d cancelados=%d entregados=%d perdidos=%d descartados=%d rechazadas=%d | %.2f resp/s | error %.1f%%\n",
+		total.Round(time.Second), cur.Created, cur.Succeeded, cur.Failed, cur.Retries,
+		cur.Cancelled, cur.Delivered, cur.Lost, cur.Dropped, cur.Rejected, rate, cur.ErrorRate()*100)
+}
@@ -1,59 +1,175 @@
 package main
 
 import (
-	"geova-simulation/assets"
-	"geova-simulation/game"
+	"context"
+	"errors"
+	"flag"
+	"geova-simulation/config"
+	"geova-simulation/headless"
+	"geova-simulation/metrics"
+	"geova-simulation/mockserver"
+	"geova-simulation/pipeline"
+	"geova-simulation/simulation"
 	"geova-simulation/state"
-	"image"
 	"log"
-	"math/rand"
-	"time"
-
-	"github.com/hajimehoshi/ebiten/v2"
-)
-
-// --- Constantes Globales ---
-const (
-	windowWidth  = 900
-	windowHeight = 650
+	"os"
+	"os/signal"
 )
 
 func main() {
-	// 1. Inicializa el generador de números aleatorios (¡Importante!)
-	// (En Go 1.20+ esto ya no es necesario, pero no hace daño)
-	rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// 2. Cargar todos los Assets
-	// Llama a la función LoadAssets que definimos en el paquete 'assets'
-	gameAssets := assets.LoadAssets()
-	log.Println("✅ Todos los assets cargados.")
+	// 1. Leer configuración (archivo JSON, variables de entorno y flags)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := simulation.LoadCaptures(cfg.Projects); err != nil {
+		log.Fatal(err)
+	}
+	var mock *mockserver.Server
+	if cfg.Mock.Enabled {
+		mock = mockserver.New(cfg.Mock, cfg.Endpoints)
+		baseURL, err := mock.Start()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer mock.Close()
+		cfg.Endpoints.SetBaseURL(baseURL)
+		log.Printf("🧪 API mock escuchando en %s", baseURL)
+	}
+	// La semilla se imprime siempre para poder repetir la corrida con -seed
+	log.Printf("🎲 Semilla: %d", cfg.Seed)
+	for kind, ep := range cfg.Endpoints {
+		log.Printf("🔗 %s → %s %s", kind, ep.HTTPMethod(), ep.URL())
+	}
 
-	// 3. Crear el Estado Compartido
+	// 2. Crear el Estado Compartido
 	// Este es el objeto que las goroutines (workers) y la UI (game)
 	// usarán para comunicarse.
 	visualState := &state.VisualState{
-		Packets:      make(map[string]*state.PacketState),
-		CurrentTilt:  0.0, // Inclinación inicial
+		Packets:     make(map[string]*state.PacketState),
+		CurrentTilt: 0.0, // Inclinación inicial
+		Latency:     metrics.NewLatencySet(),
+		Retention:   cfg.Retention,
 	}
 
-	// 4. Crear la Instancia del Juego
-	// Define la "zona de clic" para el botón de crear
-	// (Ajusta estos números para mover tu botón)
-	btnX0 := float64(windowWidth - 120) // Esquina derecha
-	btnY0 := float64(windowHeight - 60) // Abajo
-	btnRect := image.Rect(int(btnX0), int(btnY0), int(btnX0+100), int(btnY0+40)) // (100x40 de tamaño)
-
-	juego := game.NewGame(gameAssets, visualState, btnRect)
+	// El reloj de la simulación lo comparten el runner y la FSM.
+	clock := simulation.NewClock()
+	clock.SetSpeed(cfg.Speed)
+	visualState.Clock = clock
 
-	// 5. Configurar y Correr Ebitengine
-	ebiten.SetWindowSize(windowWidth, windowHeight)
-	ebiten.SetWindowTitle("Simulación de Flujo Geova (Concurrente)")
-	
-	log.Println("🚀 Iniciando simulación...")
-	
-	// ebiten.RunGame toma control del hilo principal
-	// y empezará a llamar a juego.Update() y juego.Draw()
-	if err := ebiten.RunGame(juego); err != nil {
+	runner := &simulation.Runner{
+		State:      visualState,
+		Client:     simulation.NewHTTPClient(cfg.HTTP),
+		Endpoints:  cfg.Endpoints,
+		Interval:   cfg.BatchInterval,
+		Seed:       cfg.Seed,
+		FixedClock: cfg.FixedTimestamps,
+		Clock:      clock,
+		Projects:   cfg.Projects,
+		Fleet:      cfg.Fleet,
+		Load:       cfg.Load,
+	}
+	topology, err := simulation.NewTopology(cfg.Topology, simulation.DeriveRand(cfg.Seed, "topology"))
+	if err != nil {
 		log.Fatal(err)
 	}
-}
\ No newline at end of file
+	broker, err := simulation.NewBroker(cfg.Broker, topology.StagesOf(simulation.StageQueue), simulation.DeriveRand(cfg.Seed, "broker"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner.Topology = topology
+	if cfg.Session.Replay != "" {
+		packets, err := simulation.LoadSession(cfg.Session.Replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runner.Replay, runner.ReplaySpeed = packets, cfg.Session.ReplaySpeed
+		log.Printf("⏪ Replay de %d paquetes desde %s", len(packets), cfg.Session.Replay)
+	}
+	var observers state.Observers
+	var recorder *simulation.Recorder
+	if cfg.Session.Record != "" {
+		recorder, err = simulation.NewRecorder(cfg.Session.Record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer recorder.Close()
+		observers = append(observers, recorder)
+		runner.Recorder = recorder
+		log.Printf("⏺ Grabando sesión en %s", cfg.Session.Record)
+	}
+	var tracer *simulation.Tracer
+	if cfg.Session.Trace != "" {
+		tracer, err = simulation.NewTracer(cfg.Session.Trace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tracer.Close()
+		observers = append(observers, tracer)
+		runner.Tracer = tracer
+		log.Printf("🧵 Trazas OTLP-JSON en %s", cfg.Session.Trace)
+	}
+	var exporter *simulation.Exporter
+	if cfg.Metrics.Addr != "" {
+		exporter = simulation.NewExporter(visualState)
+		exporter.Stages = topology.IDs()
+		url, err := exporter.Start(cfg.Metrics.Addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer exporter.Close()
+		observers = append(observers, exporter)
+		log.Printf("📈 Métricas de Prometheus en %s", url)
+	}
+	var confirmer *simulation.Confirmer
+	if cfg.Confirm.Enabled {
+		if cfg.Confirm.URL == "" {
+			cfg.Confirm.URL = "ws://localhost:8000" + mockserver.BroadcastPath
+			if mock != nil {
+				cfg.Confirm.URL = mock.BroadcastURL()
+			}
+		}
+		confirmer = simulation.NewConfirmer(cfg.Confirm)
+		confirmer.Clock = clock
+		confirmer.Start(context.Background())
+		observers = append(observers, confirmer)
+		log.Printf("📡 Confirmación por WebSocket en %s (timeout %v)", cfg.Confirm.URL, confirmer.Timeout)
+	}
+	if len(observers) > 0 {
+		visualState.Observer = observers
+	}
+	for _, p := range cfg.Projects {
+		visualState.Projects = append(visualState.Projects, state.ProjectInfo{ID: p.ID, Name: p.Name})
+	}
+	runner.PublishDevices()
+	pl := pipeline.New(visualState, runner, broker, topology)
+	pl.Confirmer = confirmer
+
+	// 3. Modo headless: mismo loop de batches y FSM, sin ventana
+	if cfg.Headless.Enabled {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		code := headless.Run(ctx, pl, headless.Options{
+			Duration:       cfg.Headless.Duration,
+			MaxPackets:     cfg.Headless.MaxPackets,
+			MaxErrorRate:   cfg.Headless.MaxErrorRate,
+			ReportInterval: cfg.Headless.ReportInterval,
+			TickRate:       pipeline.TickRate,
+			DrainTimeout:   headless.DefaultOptions().DrainTimeout,
+		})
+		stop()
+		mock.Close()
+		recorder.Close()
+		tracer.Close()
+		exporter.Close()
+		os.Exit(code)
+	}
+
+	// 4. Ventana (ver window.go; sin ella con -tags nogui)
+	runWindow(pl)
+	if err := runner.WriteSummary(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Histogram registra latencias con precisión relativa constante, al estilo
+// HDR: los valores menores a 128µs se guardan exactos y los mayores en 64
+// sub-buckets por potencia de dos (error < 1.6%). El tamaño es fijo sin
+// importar cuántas muestras se registren.
+type Histogram struct {
+	mutex  sync.Mutex
+	counts [bucketCount]uint64
+	total  uint64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+const (
+	subBuckets  = 128
+	halfBuckets = subBuckets / 2
+	bucketCount = subBuckets + 40*halfBuckets
+)
+
+func bucketOf(us int64) int {
+	if us < subBuckets {
+		return int(us)
+	}
+	e := bits.Len64(uint64(us)) - bits.Len64(subBuckets-1)
+	idx := subBuckets + (e-1)*halfBuckets + int(us>>uint(e)) - halfBuckets
+	if idx >= bucketCount {
+		return bucketCount - 1
+	}
+	return idx
+}
+
+// valueOf retorna el punto medio del bucket en microsegundos.
+func valueOf(idx int) int64 {
+	if idx < subBuckets {
+		return int64(idx)
+	}
+	k := idx - subBuckets
+	e := uint(k/halfBuckets + 1)
+	m := int64(k%halfBuckets + halfBuckets)
+	return (m<<e + (m+1)<<e - 1) / 2
+}
+
+// Record agrega una muestra.
+func (h *Histogram) Record(d time.Duration) {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.counts[bucketOf(us)]++
+	if h.total == 0 || us < h.min {
+		h.min = us
+	}
+	if us > h.max {
+		h.max = us
+	}
+	h.total++
+	h.sum += float64(us)
+}
+
+// Summary es una foto de un histograma.
+type Summary struct {
+	Name  string        `json:"name"`
+	Count uint64        `json:"count"`
+	Mean  time.Duration `json:"-"`
+	Min   time.Duration `json:"-"`
+	P50   time.Duration `json:"-"`
+	P95   time.Duration `json:"-"`
+	P99   time.Duration `json:"-"`
+	Max   time.Duration `json:"-"`
+
+	// Versiones en milisegundos para el archivo de resumen.
+	MeanMs float64 `json:"mean_ms"`
+	MinMs  float64 `json:"min_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// Summary calcula percentiles y extremos.
+func (h *Histogram) Summary(name string) Summary {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := Summary{Name: name, Count: h.total}
+	if h.total == 0 {
+		return s
+	}
+	s.Mean = time.Duration(h.sum/float64(h.total)) * time.Microsecond
+	s.Min = time.Duration(h.min) * time.Microsecond
+	s.Max = time.Duration(h.max) * time.Microsecond
+	s.P50 = h.quantile(0.50)
+	s.P95 = h.quantile(0.95)
+	s.P99 = h.quantile(0.99)
+
+	toMs := func(d time.Duration) float64 { return math.Round(float64(d)/1e3) / 1e3 }
+	s.MeanMs, s.MinMs, s.MaxMs = toMs(s.Mean), toMs(s.Min), toMs(s.Max)
+	s.P50Ms, s.P95Ms, s.P99Ms = toMs(s.P50), toMs(s.P95), toMs(s.P99)
+	return s
+}
+
+func (h *Histogram) quantile(q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for idx, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := valueOf(idx)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Total retorna la cantidad de muestras y su suma.
+func (h *Histogram) Total() (uint64, time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.total, time.Duration(h.sum) * time.Microsecond
+}
+
+// Buckets recorre los buckets no vacíos en orden, con su límite superior en
+// microsegundos y su cantidad de muestras, y retorna lo mismo que Total
+// bajo el mismo lock, para que los buckets y el total sean consistentes.
+func (h *Histogram) Buckets(fn func(upperUs int64, count uint64)) (uint64, time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for idx, c := range h.counts {
+		if c > 0 {
+			fn(upperOf(idx), c)
+		}
+	}
+	return h.total, time.Duration(h.sum) * time.Microsecond
+}
+
+func upperOf(idx int) int64 {
+	if idx < subBuckets {
+		return int64(idx)
+	}
+	k := idx - subBuckets
+	e := uint(k/halfBuckets + 1)
+	m := int64(k%halfBuckets + halfBuckets)
+	return (m+1)<<e - 1
+}
+
+// LatencySet agrupa un histograma por endpoint.
+type LatencySet struct {
+	mutex      sync.Mutex
+	histograms map[string]*Histogram
+}
+
+// NewLatencySet crea un conjunto vacío.
+func NewLatencySet() *LatencySet {
+	return &LatencySet{histograms: make(map[string]*Histogram)}
+}
+
+// Record agrega una muestra al histograma de name. Es seguro sobre nil.
+func (l *LatencySet) Record(name string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.Get(name).Record(d)
+}
+
+// Get retorna (creando si hace falta) el histograma de name.
+func (l *LatencySet) Get(name string) *Histogram {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h, ok := l.histograms[name]
+	if !ok {
+		h = &Histogram{}
+		l.histograms[name] = h
+	}
+	return h
+}
+
+// Reset descarta todas las muestras. Es seguro sobre nil.
+func (l *LatencySet) Reset() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.histograms = make(map[string]*Histogram)
+}
+
+// Summaries retorna un resumen por endpoint, ordenado por nombre.
+func (l *LatencySet) Summaries() []Summary {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	names := make([]string, 0, len(l.histograms))
+	for name := range l.histograms {
+		names = append(names, name)
+	}
+	l.mutex.Unlock()
+	sort.Strings(names)
+
+	out := make([]Summary, 0, len(names))
+	for _, name := range names {
+		out = append(out, l.Get(name).Summary(name))
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketBounds(t *testing.T) {
+	for _, us := range []int64{0, 1, 127, 128, 129, 255, 256, 1000, 4999, 5000, 123456, 10_000_000} {
+		idx := bucketOf(us)
+		if up := upperOf(idx); us > up {
+			t.Errorf("%dµs cae en el bucket %d, cuyo límite es %dµs", us, idx, up)
+		}
+		if idx > 0 && us <= upperOf(idx-1) {
+			t.Errorf("%dµs cae en el bucket %d pero entra en el anterior (hasta %dµs)", us, idx, upperOf(idx-1))
+		}
+		if v := valueOf(idx); us >= subBuckets && (float64(v-us) > 0.016*float64(us) || float64(us-v) > 0.016*float64(us)) {
+			t.Errorf("%dµs se representa como %dµs, más de 1.6%% de error", us, v)
+		}
+	}
+}
+
+func TestSummaryQuantiles(t *testing.T) {
+	h := &Histogram{}
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	s := h.Summary("x")
+	tests := []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"min", s.Min, time.Millisecond},
+		{"p50", s.P50, 50 * time.Millisecond},
+		{"p99", s.P99, 99 * time.Millisecond},
+		{"max", s.Max, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		diff := tt.got - tt.want
+		if diff < 0 {
+			diff = -diff
+		}
+		if float64(diff) > 0.016*float64(tt.want) {
+			t.Errorf("%s = %v, se esperaba %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if s.Count != 100 {
+		t.Errorf("Count = %d, se esperaba 100", s.Count)
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	h := &Histogram{}
+	for _, d := range []time.Duration{3 * time.Millisecond, 7 * time.Millisecond, 7 * time.Millisecond, 2 * time.Second, 30 * time.Second} {
+		h.Record(d)
+	}
+	var buf bytes.Buffer
+	WriteHistogram(&buf, "lat", Labels{"sensor": "mpu"}, h, []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 5 * time.Second})
+
+	for _, want := range []string{
+		`lat_bucket{le="0.005",sensor="mpu"} 1`,
+		`lat_bucket{le="0.01",sensor="mpu"} 3`,
+		`lat_bucket{le="5",sensor="mpu"} 4`,
+		`lat_bucket{le="+Inf",sensor="mpu"} 5`,
+		`lat_count{sensor="mpu"} 5`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("falta %q en:\n%s", want, buf.String())
+		}
+	}
+}
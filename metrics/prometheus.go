@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBounds son los límites de bucket (le) con los que se exporta un
+// Histogram en formato Prometheus.
+var DefaultBounds = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second,
+}
+
+// Labels son las etiquetas de una muestra; se escriben ordenadas por nombre.
+type Labels map[string]string
+
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.Quote(l[k])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// with retorna una copia de l con name=value agregado.
+func (l Labels) with(name, value string) Labels {
+	out := make(Labels, len(l)+1)
+	for k, v := range l {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+// WriteHeader escribe las líneas HELP y TYPE de una métrica.
+func WriteHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// WriteSample escribe una muestra en el formato de texto de Prometheus.
+func WriteSample(w io.Writer, name string, labels Labels, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// WriteHistogram escribe h como histograma de Prometheus en segundos, con
+// los buckets acumulados en bounds más +Inf. Cada bucket de h cuenta en el
+// primer límite que cubre su extremo superior.
+func WriteHistogram(w io.Writer, name string, labels Labels, h *Histogram, bounds []time.Duration) {
+	cumulative := make([]uint64, len(bounds))
+	total, sum := h.Buckets(func(upperUs int64, count uint64) {
+		for i, b := range bounds {
+			if upperUs <= b.Microseconds() {
+				cumulative[i] += count
+			}
+		}
+	})
+	for i, b := range bounds {
+		WriteSample(w, name+"_bucket", labels.with("le", strconv.FormatFloat(b.Seconds(), 'g', -1, 64)), float64(cumulative[i]))
+	}
+	WriteSample(w, name+"_bucket", labels.with("le", "+Inf"), float64(total))
+	WriteSample(w, name+"_sum", labels, sum.Seconds())
+	WriteSample(w, name+"_count", labels, float64(total))
+}
@@ -0,0 +1,104 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// BroadcastPath es donde el mock imita el WebSocket de broadcast del
+// backend: cada lectura aceptada se reenvía a todos los clientes conectados.
+const BroadcastPath = "/ws"
+
+// BroadcastMessage es lo que reciben los clientes del broadcast.
+type BroadcastMessage struct {
+	Sensor    string      `json:"sensor"`
+	RequestID string      `json:"request_id,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+type hub struct {
+	mutex   sync.Mutex
+	clients map[*websocket.Conn]chan []byte
+}
+
+var upgrader = websocket.Upgrader{
+	// El stand-in es local; se acepta cualquier origen.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[*websocket.Conn]chan []byte)}
+}
+
+func (h *hub) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	send := make(chan []byte, 256)
+	h.mutex.Lock()
+	h.clients[conn] = send
+	h.mutex.Unlock()
+	fmt.Printf("[MOCK] Cliente WebSocket conectado (%s)\n", r.RemoteAddr)
+
+	// Se lee sólo para detectar el cierre del cliente.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				h.remove(conn)
+				return
+			}
+		}
+	}()
+
+	for msg := range send {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			h.remove(conn)
+			return
+		}
+	}
+}
+
+// broadcast encola el mensaje para cada cliente; los que no dan abasto se
+// desconectan en vez de frenar a la API.
+func (h *hub) broadcast(m BroadcastMessage) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for conn, send := range h.clients {
+		select {
+		case send <- data:
+		default:
+			delete(h.clients, conn)
+			close(send)
+			conn.Close()
+		}
+	}
+}
+
+func (h *hub) remove(conn *websocket.Conn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if send, ok := h.clients[conn]; ok {
+		delete(h.clients, conn)
+		close(send)
+		conn.Close()
+	}
+}
+
+func (h *hub) closeAll() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for conn, send := range h.clients {
+		delete(h.clients, conn)
+		close(send)
+		conn.Close()
+	}
+}
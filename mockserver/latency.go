@@ -0,0 +1,7 @@
+package mockserver
+
+import "geova-simulation/simulation"
+
+// Latency describe la distribución del tiempo de respuesta de un endpoint
+// (ver simulation.Distribution).
+type Latency = simulation.Distribution
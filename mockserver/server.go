@@ -0,0 +1,253 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"geova-simulation/simulation"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Behavior controla cómo responde el mock a un endpoint.
+type Behavior struct {
+	Latency     Latency `json:"latency"`
+	ErrorRate   float64 `json:"error_rate"`   // fracción de respuestas con ErrorStatus
+	ErrorStatus int     `json:"error_status"` // 500 si no se especifica
+	TimeoutRate float64 `json:"timeout_rate"` // fracción de peticiones que se cuelgan
+	TimeoutMs   float64 `json:"timeout_ms"`   // cuánto se cuelgan antes de responder 504
+}
+
+// Config agrupa las opciones del servidor mock.
+type Config struct {
+	Enabled   bool                               `json:"enabled"`
+	Addr      string                             `json:"addr"`
+	Seed      int64                              `json:"seed"`
+	Default   Behavior                           `json:"default"`
+	Endpoints map[simulation.SensorKind]Behavior `json:"endpoints"`
+
+	// BroadcastLossRate es la fracción de lecturas aceptadas que no se
+	// reenvían por BroadcastPath, para simular mensajes perdidos.
+	BroadcastLossRate float64 `json:"broadcast_loss_rate"`
+}
+
+// DefaultConfig responde rápido y sin errores en 127.0.0.1:8000.
+func DefaultConfig() Config {
+	return Config{
+		Addr: "127.0.0.1:8000",
+		Default: Behavior{
+			Latency:     Latency{Kind: "uniform", MinMs: 20, MaxMs: 80},
+			ErrorStatus: http.StatusInternalServerError,
+			TimeoutMs:   30000,
+		},
+	}
+}
+
+// BehaviorFor retorna el comportamiento del sensor, heredando de Default
+// los campos que no se hayan especificado.
+func (c Config) BehaviorFor(kind simulation.SensorKind) Behavior {
+	b, ok := c.Endpoints[kind]
+	if !ok {
+		return c.Default
+	}
+	if b.Latency.Kind == "" {
+		b.Latency = c.Default.Latency
+	}
+	if b.ErrorStatus == 0 {
+		b.ErrorStatus = c.Default.ErrorStatus
+	}
+	if b.TimeoutMs == 0 {
+		b.TimeoutMs = c.Default.TimeoutMs
+	}
+	return b
+}
+
+// Server es una versión en memoria de la API de Geova.
+type Server struct {
+	cfg      Config
+	routes   map[string]route
+	listener net.Listener
+	http     *http.Server
+	hub      *hub
+
+	rngMutex sync.Mutex
+	rng      *rand.Rand
+}
+
+// route es el sensor que atiende una ruta y el método que acepta.
+type route struct {
+	kind   simulation.SensorKind
+	method string
+}
+
+// New crea el servidor. Las rutas se toman de endpoints para que el mock
+// responda exactamente donde el simulador va a enviar.
+func New(cfg Config, endpoints simulation.EndpointRegistry) *Server {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s := &Server{
+		cfg:    cfg,
+		routes: make(map[string]route),
+		hub:    newHub(),
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+	for kind, ep := range endpoints {
+		s.routes["/"+strings.TrimLeft(ep.Path, "/")] = route{kind: kind, method: ep.HTTPMethod()}
+	}
+	return s
+}
+
+// Start escucha en cfg.Addr y atiende peticiones en una goroutine.
+// Retorna la URL base a la que hay que apuntar los endpoints.
+func (s *Server) Start() (string, error) {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return "", fmt.Errorf("mock: no se pudo escuchar en %s: %w", s.cfg.Addr, err)
+	}
+	s.listener = ln
+	s.http = &http.Server{Handler: s}
+
+	go func() {
+		if err := s.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("[MOCK] Error del servidor: %v\n", err)
+		}
+	}()
+
+	return "http://" + ln.Addr().String(), nil
+}
+
+// BroadcastURL es la URL del WebSocket de broadcast; sólo es válida tras
+// Start.
+func (s *Server) BroadcastURL() string {
+	return "ws://" + s.listener.Addr().String() + BroadcastPath
+}
+
+// Close detiene el servidor. Es seguro llamarlo sobre un *Server nil.
+func (s *Server) Close() error {
+	if s == nil || s.http == nil {
+		return nil
+	}
+	s.hub.closeAll()
+	return s.http.Close()
+}
+
+// ServeHTTP implementa http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == BroadcastPath {
+		s.hub.serveWS(w, r)
+		return
+	}
+	rt, ok := s.routes[r.URL.Path]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Not Found"})
+		return
+	}
+	kind := rt.kind
+	if r.Method != rt.method {
+		w.Header().Set("Allow", rt.method)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"detail": "Method Not Allowed"})
+		return
+	}
+
+	rid := r.Header.Get(simulation.RequestIDHeader)
+	payload, err := decodeAndValidate(kind, r)
+	if err != nil {
+		fmt.Printf("[MOCK] %s rid=%s rechazado: %v\n", kind, rid, err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"detail": err.Error()})
+		return
+	}
+
+	b := s.cfg.BehaviorFor(kind)
+	latency, roll := s.sample(b.Latency)
+
+	switch {
+	case roll < b.TimeoutRate:
+		if !sleepCtx(r, time.Duration(b.TimeoutMs*float64(time.Millisecond))) {
+			return
+		}
+		writeJSON(w, http.StatusGatewayTimeout, map[string]string{"detail": "Gateway Timeout"})
+
+	case roll < b.TimeoutRate+b.ErrorRate:
+		if !sleepCtx(r, latency) {
+			return
+		}
+		writeJSON(w, b.ErrorStatus, map[string]string{"detail": http.StatusText(b.ErrorStatus)})
+
+	default:
+		if !sleepCtx(r, latency) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "sensor": string(kind)})
+		if s.cfg.BroadcastLossRate == 0 || s.roll() >= s.cfg.BroadcastLossRate {
+			s.hub.broadcast(BroadcastMessage{Sensor: string(kind), RequestID: rid, Data: payload})
+		}
+	}
+}
+
+// sample sortea la latencia y el dado de fallo bajo el mismo lock, ya que
+// rand.Rand no es seguro para uso concurrente.
+func (s *Server) sample(l Latency) (time.Duration, float64) {
+	s.rngMutex.Lock()
+	defer s.rngMutex.Unlock()
+	return l.Sample(s.rng), s.rng.Float64()
+}
+
+func (s *Server) roll() float64 {
+	s.rngMutex.Lock()
+	defer s.rngMutex.Unlock()
+	return s.rng.Float64()
+}
+
+// sleepCtx espera d o hasta que el cliente cancele; retorna false si canceló.
+func sleepCtx(r *http.Request, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func decodeAndValidate(kind simulation.SensorKind, r *http.Request) (interface{}, error) {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var payload interface{ Validate() error }
+	switch kind {
+	case simulation.SensorTFLuna:
+		var d simulation.TFLunaData
+		if err := dec.Decode(&d); err != nil {
+			return nil, err
+		}
+		payload = d
+	case simulation.SensorMPU:
+		var d simulation.MPUData
+		if err := dec.Decode(&d); err != nil {
+			return nil, err
+		}
+		payload = d
+	case simulation.SensorIMX:
+		var d simulation.IMXData
+		if err := dec.Decode(&d); err != nil {
+			return nil, err
+		}
+		payload = d
+	default:
+		return nil, fmt.Errorf("sensor desconocido '%s'", kind)
+	}
+	return payload, payload.Validate()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
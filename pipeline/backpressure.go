@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"fmt"
+	"geova-simulation/simulation"
+	"geova-simulation/state"
+)
+
+// stageLoad cuenta, por etapa, los paquetes que ocupan un lugar (busy) y los
+// que van en camino (incoming). Se recalcula al principio de cada tick y se
+// actualiza a medida que la FSM mueve paquetes.
+type stageLoad struct {
+	busy     map[string]int
+	incoming map[string]int
+}
+
+func (pl *Pipeline) countLoad() {
+	pl.load = stageLoad{busy: make(map[string]int), incoming: make(map[string]int)}
+	for _, p := range pl.State.Packets {
+		switch p.Status {
+		case state.ProcessingAtStage:
+			pl.load.busy[p.Stage]++
+		case state.SendingToStage:
+			pl.load.incoming[p.Stage]++
+		}
+	}
+}
+
+// hasSlot indica si stage puede procesar un paquete más. Las colas aceptan
+// mientras el broker acepte, y no hay forma de saberlo sin publicar: se
+// asume que sí mientras no tengan fila.
+func (pl *Pipeline) hasSlot(stage simulation.StageConfig) bool {
+	if stage.Kind == simulation.StageQueue {
+		return len(pl.State.StageLines[stage.ID]) == 0
+	}
+	return stage.Capacity == 0 || pl.load.busy[stage.ID] < stage.Capacity
+}
+
+// full indica si un paquete más para stage no tendría lugar ni en la fila,
+// contando los que ya van en camino.
+func (pl *Pipeline) full(stage simulation.StageConfig) bool {
+	if stage.Kind == simulation.StageFrontend || stage.QueueLimit == 0 {
+		return false
+	}
+	waiting := len(pl.State.StageLines[stage.ID]) + pl.load.incoming[stage.ID]
+	if stage.Kind == simulation.StageQueue {
+		return len(pl.State.StageLines[stage.ID]) > 0 && waiting >= stage.QueueLimit
+	}
+	return pl.load.busy[stage.ID]+waiting >= stage.Capacity+stage.QueueLimit
+}
+
+// admit mete al paquete en un lugar de stage si hay. Retorna false si está
+// ocupada.
+func (pl *Pipeline) admit(packet *state.PacketState, stage simulation.StageConfig) bool {
+	if stage.Kind == simulation.StageQueue {
+		if pl.Broker.Publish(stage.ID, simulation.SensorKind(packet.Sensor), packet.ID) != nil {
+			return false
+		}
+		packet.TargetX, packet.TargetY = stage.X, stage.Y
+		return pl.State.SetStatus(packet, state.ProcessingAtStage)
+	}
+	if !pl.hasSlot(stage) {
+		return false
+	}
+
+	packet.ProcessingTimer = pl.Topology.ProcessTime(stage.ID)
+	if packet.ProcessingTimer > pl.State.StageActivity[stage.ID] {
+		pl.State.StageActivity[stage.ID] = packet.ProcessingTimer
+	}
+	packet.TargetX, packet.TargetY = stage.X, stage.Y
+	if !pl.State.SetStatus(packet, state.ProcessingAtStage) {
+		return false
+	}
+	pl.load.busy[stage.ID]++
+	return true
+}
+
+// enqueue pone al paquete al final de la fila de stage, aplicando la
+// política de backpressure si está llena.
+func (pl *Pipeline) enqueue(packet *state.PacketState, stage simulation.StageConfig) {
+	line := pl.State.StageLines[stage.ID]
+	if stage.QueueLimit > 0 && len(line) >= stage.QueueLimit {
+		switch stage.Backpressure {
+		case simulation.BackpressureDropNewest:
+			pl.drop(packet, stage)
+			return
+		case simulation.BackpressureDropOldest:
+			if oldest, ok := pl.State.Packets[line[0]]; ok {
+				pl.drop(oldest, stage)
+			}
+			line = line[1:]
+		}
+		// Con block sólo se llega acá desde la API o por paquetes que ya
+		// venían en camino: la fila pasa del límite.
+	}
+	if !pl.State.SetStatus(packet, state.WaitingAtStage) {
+		return
+	}
+	pl.State.StageLines[stage.ID] = append(line, packet.ID)
+	pl.placeLine(stage)
+}
+
+// admitWaiting hace entrar a los primeros de cada fila mientras haya lugar.
+func (pl *Pipeline) admitWaiting() {
+	for _, stage := range pl.Topology.Stages {
+		line := pl.State.StageLines[stage.ID]
+		n := 0
+		for n < len(line) {
+			packet, ok := pl.State.Packets[line[n]]
+			if ok && !pl.admit(packet, stage) {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			pl.State.StageLines[stage.ID] = line[n:]
+			pl.placeLine(stage)
+		}
+	}
+}
+
+// placeLine ubica la fila bajo el icono de la etapa en dos columnas; los que
+// no entran en pantalla se apilan en el último lugar.
+func (pl *Pipeline) placeLine(stage simulation.StageConfig) {
+	for i, id := range pl.State.StageLines[stage.ID] {
+		packet, ok := pl.State.Packets[id]
+		if !ok {
+			continue
+		}
+		spot := i
+		if spot >= 2*lineRows {
+			spot = 2*lineRows - 1
+		}
+		packet.TargetX = stage.X + float64(spot%2)*lineSpacingX
+		packet.TargetY = stage.Y + lineOffsetY + float64(spot/2)*lineSpacingY
+	}
+}
+
+func (pl *Pipeline) drop(packet *state.PacketState, stage simulation.StageConfig) {
+	fmt.Printf("[%s] ✗ Descartado en %s: fila llena (%d, %s)\n", packet.LogTag(), stage.ID, stage.QueueLimit, stage.Backpressure)
+	if pl.Confirmer != nil {
+		pl.Confirmer.Forget(packet.ID)
+	}
+	pl.State.SetStatus(packet, state.Dropped)
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"fmt"
+	"geova-simulation/simulation"
+	"geova-simulation/state"
+	"math/rand"
+	"testing"
+)
+
+// newTestPipeline arma un pipeline sobre stages (la primera es la api y van
+// en cadena hasta un frontend) sin runner ni broker.
+func newTestPipeline(t *testing.T, stages ...simulation.StageConfig) *Pipeline {
+	t.Helper()
+	cfg := simulation.TopologyConfig{Stages: append(stages, simulation.StageConfig{ID: "front", Kind: simulation.StageFrontend, X: 600})}
+	for i := 1; i < len(cfg.Stages); i++ {
+		cfg.Edges = append(cfg.Edges, simulation.EdgeConfig{From: cfg.Stages[i-1].ID, To: cfg.Stages[i].ID})
+	}
+	topo, err := simulation.NewTopology(cfg, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := New(&state.VisualState{Packets: make(map[string]*state.PacketState)}, nil, nil, topo)
+	pl.countLoad()
+	return pl
+}
+
+// arrive crea un paquete que la API acaba de aceptar y lo hace entrar a la
+// primera etapa, como en el tick en que llega.
+func arrive(pl *Pipeline, id string) *state.PacketState {
+	p := &state.PacketState{ID: id, Sensor: "mpu", Status: state.ArrivedAtAPI, Active: true}
+	pl.State.Packets[id] = p
+	pl.arriveAtAPI(p)
+	return p
+}
+
+// statuses lista el estado de cada id, en orden.
+func statuses(pl *Pipeline, ids ...string) string {
+	var out []string
+	for _, id := range ids {
+		out = append(out, fmt.Sprintf("%s=%s", id, pl.State.Packets[id].Status))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestBackpressureDrop(t *testing.T) {
+	tests := []struct {
+		policy  simulation.BackpressurePolicy
+		dropped string
+		line    string
+	}{
+		{simulation.BackpressureDropNewest, "p3", "[p1 p2]"},
+		{simulation.BackpressureDropOldest, "p1", "[p2 p3]"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			pl := newTestPipeline(t, simulation.StageConfig{ID: "api", Kind: simulation.StageAPI, X: 250,
+				Capacity: 1, QueueLimit: 2, Backpressure: tt.policy})
+			for i := 0; i < 4; i++ {
+				arrive(pl, fmt.Sprintf("p%d", i))
+			}
+
+			if p := pl.State.Packets["p0"]; p.Status != state.ProcessingAtStage {
+				t.Errorf("p0 %s, se esperaba que ocupara el único lugar", p.Status)
+			}
+			if got := fmt.Sprint(pl.State.StageLines["api"]); got != tt.line {
+				t.Errorf("fila %s, se esperaba %s (%s)", got, tt.line, statuses(pl, "p0", "p1", "p2", "p3"))
+			}
+			if p := pl.State.Packets[tt.dropped]; p.Status != state.Dropped || p.Active {
+				t.Errorf("%s %s (activo %v), se esperaba descartado", tt.dropped, p.Status, p.Active)
+			}
+			if pl.State.Stats.Dropped != 1 {
+				t.Errorf("Stats.Dropped = %d, se esperaba 1", pl.State.Stats.Dropped)
+			}
+		})
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	pl := newTestPipeline(t,
+		simulation.StageConfig{ID: "api", Kind: simulation.StageAPI, X: 250},
+		simulation.StageConfig{ID: "db", X: 400, Capacity: 1, QueueLimit: 1, Backpressure: simulation.BackpressureBlock},
+	)
+	// sendOn saca al paquete de la api y lo deja en la puerta de db.
+	sendOn := func(p *state.PacketState) bool {
+		p.ProcessingTimer = 0
+		pl.process(p)
+		if p.Status != state.SendingToStage {
+			return false
+		}
+		p.X, p.Y = p.TargetX, p.TargetY
+		pl.arriveAtStage(p)
+		return true
+	}
+
+	a, b, c := arrive(pl, "a"), arrive(pl, "b"), arrive(pl, "c")
+	if !sendOn(a) || !sendOn(b) {
+		t.Fatalf("con db libre no salieron de la api: %s", statuses(pl, "a", "b", "c"))
+	}
+	if a.Status != state.ProcessingAtStage || fmt.Sprint(pl.State.StageLines["db"]) != "[b]" {
+		t.Fatalf("db: %s, fila %v", statuses(pl, "a", "b"), pl.State.StageLines["db"])
+	}
+
+	// db está llena (un lugar y una fila de 1): c se queda en la api.
+	if sendOn(c) {
+		t.Fatalf("c salió hacia db llena: %s", statuses(pl, "a", "b", "c"))
+	}
+	if c.Status != state.ProcessingAtStage || c.Stage != "api" {
+		t.Errorf("c %s en %s, se esperaba que siguiera ocupando la api", c.Status, c.Stage)
+	}
+	if pl.State.Stats.Dropped != 0 {
+		t.Errorf("block descartó %d paquetes", pl.State.Stats.Dropped)
+	}
+
+	// Cuando a deja db, b entra y c puede salir.
+	a.ProcessingTimer = 0
+	pl.process(a)
+	pl.admitWaiting()
+	if b.Status != state.ProcessingAtStage || !sendOn(c) {
+		t.Errorf("tras liberar db: %s, fila %v", statuses(pl, "a", "b", "c"), pl.State.StageLines["db"])
+	}
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import "time"
+
+const (
+	// TickDuration es el tiempo real de un tick; cada tick adelanta
+	// TickDuration·velocidad del reloj de la simulación.
+	TickDuration = time.Second / 60
+	// TickRate son los ticks por segundo, el ritmo de Ebitengine.
+	TickRate = 60
+
+	// packetSpeed son los píxeles que avanza un paquete por tick a 1×.
+	packetSpeed = 3.0
+
+	// Zona de dead letters: los paquetes en Error se forman en una grilla
+	// bajo el monitor. Coincide con deadLetterX/deadLetterY en game.
+	deadLetterX        = 690.0
+	deadLetterY        = 392.0
+	deadLetterCols     = 5
+	deadLetterRows     = 2
+	deadLetterSpacingX = 36.0
+	deadLetterSpacingY = 30.0
+
+	// Filas de espera: dos columnas bajo el icono de la etapa, debajo de los
+	// textos de estado.
+	lineOffsetY  = 124.0
+	lineSpacingX = 34.0
+	lineSpacingY = 26.0
+	lineRows     = 5
+	// processingDelay es cuánto sigue animada una cola tras su último
+	// movimiento.
+	processingDelay = 500 * time.Millisecond
+	// retentionTicks es cada cuántos ticks se aplica State.Retention.
+	retentionTicks = 60
+)
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"fmt"
+	"geova-simulation/simulation"
+	"geova-simulation/state"
+	"math"
+)
+
+func (pl *Pipeline) updatePacketFSM() {
+	pl.State.Mutex.Lock()
+	defer pl.State.Mutex.Unlock()
+
+	for id, left := range pl.State.StageActivity {
+		if left > pl.dt {
+			pl.State.StageActivity[id] = left - pl.dt
+		} else {
+			pl.State.StageActivity[id] = 0
+		}
+	}
+	pl.countLoad()
+
+	for _, id := range pl.Broker.Tick(pl.dt) {
+		if packet, ok := pl.State.Packets[id]; ok && packet.Status == state.ProcessingAtStage {
+			pl.released[id] = true
+		}
+	}
+	for _, id := range pl.Topology.StagesOf(simulation.StageQueue) {
+		if pl.Broker.Busy(id) {
+			pl.State.StageActivity[id] = processingDelay
+		}
+	}
+	pl.admitWaiting()
+	pl.placeDeadLetters()
+
+	// A 2× un paquete avanza el doble por tick, a 0.25× un cuarto.
+	step := packetSpeed * float64(pl.dt) / float64(TickDuration)
+	allDone := true
+
+	for _, packet := range pl.State.Packets {
+		if packet.Status == state.Error {
+			// Los fallidos siguen moviéndose hasta su lugar en dead letters.
+			movePacket(packet, step)
+		}
+		if packet.Finished() {
+			continue
+		}
+
+		allDone = false
+
+		if movePacket(packet, step) {
+			pl.handlePacketArrival(packet)
+		}
+	}
+
+	if allDone && len(pl.State.Packets) > 0 {
+		pl.State.SimulacionIniciada = false
+	}
+
+	pl.ticks++
+	if pl.ticks%retentionTicks == 0 {
+		pl.State.Evict(pl.Clock.Now())
+	}
+}
+
+// movePacket acerca el paquete step píxeles a su destino y retorna si ya
+// está ahí.
+func movePacket(packet *state.PacketState, step float64) bool {
+	dx := packet.TargetX - packet.X
+	dy := packet.TargetY - packet.Y
+	distance := math.Sqrt(dx*dx + dy*dy)
+
+	if distance > step {
+		packet.X += (dx / distance) * step
+		packet.Y += (dy / distance) * step
+		return false
+	}
+	packet.X = packet.TargetX
+	packet.Y = packet.TargetY
+	return true
+}
+
+// placeDeadLetters ubica los paquetes en dead letters en la grilla de su
+// zona; los que no entran se apilan en el último lugar.
+func (pl *Pipeline) placeDeadLetters() {
+	for i, id := range pl.State.DeadLetters {
+		packet, ok := pl.State.Packets[id]
+		if !ok {
+			continue
+		}
+		spot := i
+		if spot >= deadLetterCols*deadLetterRows {
+			spot = deadLetterCols*deadLetterRows - 1
+		}
+		packet.TargetX = deadLetterX + 4 + float64(spot%deadLetterCols)*deadLetterSpacingX
+		packet.TargetY = deadLetterY + 2 + float64(spot/deadLetterCols)*deadLetterSpacingY
+	}
+}
+
+// arrivalHandlers dice qué hace la FSM en cada tick con un paquete que está
+// en su destino, según su estado. Los estados sin entrada no hacen nada:
+// SendingToAPI lo resuelve el worker (ArrivedAtAPI, Retrying o Error) y los
+// finales ya no cambian. Los cambios de estado los valida State.Machine.
+var arrivalHandlers = map[state.PacketStatus]func(pl *Pipeline, packet *state.PacketState){
+	state.ArrivedAtAPI:      (*Pipeline).arriveAtAPI,
+	state.SendingToStage:    (*Pipeline).arriveAtStage,
+	state.ProcessingAtStage: (*Pipeline).process,
+	state.AwaitingEcho:      (*Pipeline).awaitEcho,
+}
+
+// handlePacketArrival se llama en cada tick mientras el paquete está en su
+// destino.
+func (pl *Pipeline) handlePacketArrival(packet *state.PacketState) {
+	if handle, ok := arrivalHandlers[packet.Status]; ok {
+		handle(pl, packet)
+	}
+}
+
+func (pl *Pipeline) arriveAtAPI(packet *state.PacketState) {
+	pl.enterStage(packet, pl.Topology.Entry())
+}
+
+func (pl *Pipeline) arriveAtStage(packet *state.PacketState) {
+	if stage, ok := pl.Topology.Stage(packet.Stage); ok {
+		pl.enterStage(packet, stage)
+	}
+}
+
+func (pl *Pipeline) process(packet *state.PacketState) {
+	stage, _ := pl.Topology.Stage(packet.Stage)
+	switch {
+	case stage.Kind == simulation.StageQueue && !pl.released[packet.ID]:
+		// Sale cuando el broker confirma el mensaje (ver updatePacketFSM).
+	case packet.ProcessingTimer > 0:
+		packet.ProcessingTimer -= pl.dt
+	case pl.moveToNext(packet):
+		delete(pl.released, packet.ID)
+	}
+}
+
+func (pl *Pipeline) awaitEcho(packet *state.PacketState) {
+	switch {
+	case pl.Confirmer.Confirmed(packet.ID):
+		pl.Confirmer.Forget(packet.ID)
+		pl.deliver(packet)
+	case packet.ProcessingTimer > 0:
+		packet.ProcessingTimer -= pl.dt
+	default:
+		fmt.Printf("[%s] ✗ Sin mensaje del WebSocket tras %v, se da por perdido\n", packet.ID, pl.Confirmer.Timeout)
+		pl.Confirmer.Forget(packet.ID)
+		pl.State.SetStatus(packet, state.Lost)
+	}
+}
+
+// enterStage deja al paquete en stage según su tipo: en un lugar libre o en
+// la fila de espera.
+func (pl *Pipeline) enterStage(packet *state.PacketState, stage simulation.StageConfig) {
+	if packet.Status == state.SendingToStage {
+		pl.load.incoming[stage.ID]--
+	}
+	packet.Stage = stage.ID
+
+	if stage.Kind == simulation.StageFrontend {
+		if pl.Confirmer == nil {
+			pl.deliver(packet)
+			return
+		}
+		packet.ProcessingTimer = pl.Confirmer.Timeout
+		pl.State.SetStatus(packet, state.AwaitingEcho)
+		return
+	}
+
+	if len(pl.State.StageLines[stage.ID]) > 0 || !pl.admit(packet, stage) {
+		pl.enqueue(packet, stage)
+	}
+}
+
+// moveToNext manda al paquete hacia la etapa siguiente a la actual. Si esa
+// etapa bloquea y está llena, el paquete sigue ocupando su lugar y retorna
+// false; en el próximo tick se vuelve a sortear la salida.
+func (pl *Pipeline) moveToNext(packet *state.PacketState) bool {
+	next := pl.Topology.Next(packet.Stage)
+	if next.Backpressure == simulation.BackpressureBlock && pl.full(next) {
+		return false
+	}
+	from := packet.Stage
+	packet.Stage = next.ID
+	if !pl.State.SetStatus(packet, state.SendingToStage) {
+		packet.Stage = from
+		return false
+	}
+	packet.TargetX = next.X
+	packet.TargetY = next.Y
+	pl.load.busy[from]--
+	pl.load.incoming[next.ID]++
+	return true
+}
+
+func (pl *Pipeline) deliver(packet *state.PacketState) {
+	if pl.State.SetStatus(packet, state.Done) {
+		pl.updateDashboard(packet)
+	}
+}
+
+func (pl *Pipeline) updateDashboard(packet *state.PacketState) {
+	readings := pl.State.ReadingsFor(packet.ProjectID)
+	switch data := packet.Payload.(type) {
+	case simulation.TFLunaData:
+		readings.DisplayDistancia = data.DistanciaM
+	case simulation.MPUData:
+		readings.DisplayRoll = data.Roll
+	case simulation.IMXData:
+		readings.DisplayNitidez = data.Nitidez
+	}
+}
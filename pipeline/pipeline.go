@@ -0,0 +1,181 @@
+// Package pipeline mueve los paquetes por la topología: la FSM de cada
+// paquete, las filas de espera y el backpressure de las etapas. No dibuja
+// ni lee entrada, así que lo comparten la ventana (game) y el modo headless.
+package pipeline
+
+import (
+	"context"
+	"geova-simulation/simulation"
+	"geova-simulation/state"
+	"time"
+)
+
+// Pipeline avanza la simulación de a un tick con Step.
+type Pipeline struct {
+	State *state.VisualState
+
+	Runner   *simulation.Runner
+	Broker   *simulation.Broker
+	Topology *simulation.Topology
+	// Confirmer es opcional: con él, los paquetes sólo llegan a Done cuando
+	// su mensaje aparece en el WebSocket del backend.
+	Confirmer *simulation.Confirmer
+	// Clock es el reloj de la simulación, compartido con Runner: Step lo
+	// adelanta y la FSM se mueve según cuánto avanzó.
+	Clock *simulation.Clock
+
+	animPacketCounter int
+	animIconCounter   int
+	// animTime es el tiempo simulado que todavía no alcanzó para un frame
+	// de animación.
+	animTime time.Duration
+	// dt es cuánto avanzó el reloj en el tick en curso.
+	dt time.Duration
+
+	// load es la ocupación de las etapas en este tick y released los
+	// paquetes que el broker ya confirmó pero siguen bloqueados en la cola.
+	load     stageLoad
+	released map[string]bool
+	// ctx es el de la corrida en curso; los reenvíos desde dead letters
+	// salen con él. runDone se cierra cuando la corrida y sus workers
+	// terminaron.
+	ctx     context.Context
+	runDone chan struct{}
+	// ticks cuenta los ticks de la FSM.
+	ticks int
+}
+
+// New crea el pipeline sobre vs. Si runner no tiene reloj, comparte uno
+// nuevo con él.
+func New(vs *state.VisualState, runner *simulation.Runner, broker *simulation.Broker, topology *simulation.Topology) *Pipeline {
+	if vs.StageActivity == nil {
+		vs.StageActivity = make(map[string]time.Duration)
+	}
+	if vs.StageLines == nil {
+		vs.StageLines = make(map[string][]string)
+	}
+	clock := simulation.NewClock()
+	if runner != nil {
+		if runner.Clock == nil {
+			runner.Clock = clock
+		}
+		clock = runner.Clock
+	}
+	if vs.Clock == nil {
+		vs.Clock = clock
+	}
+	return &Pipeline{
+		State:    vs,
+		Runner:   runner,
+		Broker:   broker,
+		Topology: topology,
+		Clock:    clock,
+		released: make(map[string]bool),
+	}
+}
+
+// Step avanza un tick de animación y de la FSM de paquetes. El tick dura
+// TickDuration por la velocidad de Clock; en pausa no pasa nada.
+func (pl *Pipeline) Step() {
+	pl.dt = pl.Clock.Advance(TickDuration)
+	if pl.dt == 0 {
+		return
+	}
+	pl.animTime += pl.dt
+	frames := int(pl.animTime / TickDuration)
+	pl.animTime -= time.Duration(frames) * TickDuration
+	pl.animPacketCounter = (pl.animPacketCounter + frames) % 360
+	pl.animIconCounter = (pl.animIconCounter + frames) % 360
+
+	pl.updatePacketFSM()
+}
+
+// Start descarta los paquetes y contadores de la corrida anterior y arranca
+// una nueva en segundo plano.
+func (pl *Pipeline) Start() {
+	pl.State.Mutex.Lock()
+	// La FSM apaga SimulacionIniciada cuando no quedan paquetes en curso,
+	// aunque la corrida siga generando: se cancela igual.
+	if pl.State.Cancel != nil {
+		pl.State.Cancel()
+		pl.State.Cancel = nil
+	}
+	pl.State.Mutex.Unlock()
+
+	// Los workers de la corrida anterior toman Mutex para pasar a
+	// Cancelled y cuentan en Stats: hay que esperarlos antes de limpiar.
+	if pl.runDone != nil {
+		<-pl.runDone
+	}
+
+	pl.State.Mutex.Lock()
+	pl.State.Packets = make(map[string]*state.PacketState)
+	pl.State.StageLines = make(map[string][]string)
+	pl.State.DeadLetters = nil
+	pl.released = make(map[string]bool)
+	pl.State.Readings = make(map[int]*state.Readings)
+	pl.State.SimulacionIniciada = true
+	pl.State.PacketID = 0
+	pl.State.Stats = state.Stats{}
+	pl.State.Retired = state.Retired{}
+	pl.State.Latency.Reset()
+	pl.Broker.Reset()
+	if pl.Confirmer != nil {
+		pl.Confirmer.Reset()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pl.State.Cancel = cancel
+	pl.ctx = ctx
+	pl.State.Mutex.Unlock()
+
+	done := make(chan struct{})
+	pl.runDone = done
+	go func() {
+		pl.Runner.Run(ctx)
+		pl.Runner.Wait()
+		close(done)
+	}()
+}
+
+// Stop cancela la corrida en curso: deja de generar y los paquetes que
+// esperan una respuesta pasan a Cancelled. Retorna false si no había una
+// corrida iniciada.
+func (pl *Pipeline) Stop() bool {
+	pl.State.Mutex.Lock()
+	defer pl.State.Mutex.Unlock()
+	if !pl.State.SimulacionIniciada {
+		return false
+	}
+	if pl.State.Cancel != nil {
+		pl.State.Cancel()
+		pl.State.Cancel = nil
+	}
+	pl.State.SimulacionIniciada = false
+	return true
+}
+
+// Redrive reenvía un paquete de dead letters dentro de la corrida en curso.
+func (pl *Pipeline) Redrive(id string) (*state.PacketState, error) {
+	return pl.Runner.Redrive(pl.ctx, id)
+}
+
+// RedriveAll reenvía todos los paquetes de dead letters y retorna cuántos.
+func (pl *Pipeline) RedriveAll() int {
+	return pl.Runner.RedriveAll(pl.ctx)
+}
+
+// PacketFrame y IconFrame cuentan los frames de animación de los paquetes y
+// de los iconos; avanzan con el reloj de la simulación.
+func (pl *Pipeline) PacketFrame() int {
+	return pl.animPacketCounter
+}
+
+func (pl *Pipeline) IconFrame() int {
+	return pl.animIconCounter
+}
+
+// Occupied es cuántos paquetes ocupan un lugar de la etapa id en este tick.
+// Se llama con State.Mutex tomado.
+func (pl *Pipeline) Occupied(id string) int {
+	return pl.load.busy[id]
+}
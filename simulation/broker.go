@@ -0,0 +1,318 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull indica que la cola rechazó la publicación por estar llena.
+var ErrQueueFull = errors.New("cola llena")
+
+// QueueConfig describe una cola del broker y sus consumidores.
+type QueueConfig struct {
+	Name string `json:"name"`
+	// Sensors son los sensores que se publican en esta cola; vacío = todos
+	// los que no tengan otra cola.
+	Sensors []SensorKind `json:"sensors"`
+	// Capacity es el máximo de mensajes listos (sin entregar); al llenarse
+	// la publicación se rechaza. 0 = sin límite.
+	Capacity int `json:"capacity"`
+	// Consumers es la cantidad de consumidores de la cola.
+	Consumers int `json:"consumers"`
+	// Prefetch es el máximo de mensajes entregados sin ack por consumidor.
+	Prefetch int `json:"prefetch"`
+	// ProcessMs es lo que tarda un consumidor en procesar un mensaje.
+	ProcessMs int `json:"process_ms"`
+	// NackRate es la fracción de mensajes que el consumidor rechaza; vuelven
+	// al principio de la cola para reentregarse.
+	NackRate float64 `json:"nack_rate"`
+}
+
+// BrokerConfig es la topología de colas que reemplaza al temporizador fijo
+// de la etapa RabbitMQ. Cada etapa de tipo queue tiene su propio juego de
+// estas colas.
+type BrokerConfig struct {
+	Queues []QueueConfig `json:"queues"`
+}
+
+// DefaultBrokerConfig crea una cola por sensor con un consumidor que tarda lo
+// mismo que la animación original (30 frames a 60 TPS).
+func DefaultBrokerConfig() BrokerConfig {
+	var cfg BrokerConfig
+	for _, kind := range SensorKinds {
+		cfg.Queues = append(cfg.Queues, QueueConfig{
+			Name:      "geova." + string(kind),
+			Sensors:   []SensorKind{kind},
+			Capacity:  50,
+			Consumers: 1,
+			Prefetch:  1,
+			ProcessMs: 500,
+		})
+	}
+	return cfg
+}
+
+// QueueStats es una foto de una cola para dibujarla o reportarla.
+type QueueStats struct {
+	Stage     string
+	Name      string
+	Ready     int // esperando consumidor
+	Unacked   int // entregados sin ack
+	Capacity  int
+	Published int
+	Rejected  int // publicaciones rechazadas por capacidad
+	Acked     int
+	Nacked    int
+}
+
+// Broker es un modelo en memoria de un broker de mensajes: colas con
+// nombre, capacidad acotada, consumidores con prefetch y ack/nack. No tiene
+// goroutines propias; lo avanza Tick desde la FSM, así que sigue el mismo
+// reloj que la animación.
+type Broker struct {
+	mutex  sync.Mutex
+	queues []*queue
+	routes map[string]map[SensorKind]*queue // por etapa
+	rng    *rand.Rand
+}
+
+type queue struct {
+	cfg       QueueConfig
+	ready     []string
+	consumers []*consumer
+	stats     QueueStats
+}
+
+type consumer struct {
+	unacked   []string      // en orden de entrega; se procesa el primero
+	remaining time.Duration // tiempo que le falta al primero
+}
+
+// NewBroker crea las colas de cfg para cada etapa de stages. rng decide los
+// nack.
+func NewBroker(cfg BrokerConfig, stages []string, rng *rand.Rand) (*Broker, error) {
+	if len(cfg.Queues) == 0 {
+		cfg = DefaultBrokerConfig()
+	}
+	// Las colas se validan aunque ninguna etapa las use.
+	if _, _, err := newQueues("", cfg); err != nil {
+		return nil, err
+	}
+	b := &Broker{routes: make(map[string]map[SensorKind]*queue), rng: rng}
+	for _, stage := range stages {
+		queues, routes, err := newQueues(stage, cfg)
+		if err != nil {
+			return nil, err
+		}
+		b.queues = append(b.queues, queues...)
+		b.routes[stage] = routes
+	}
+	return b, nil
+}
+
+// newQueues crea las colas de cfg para stage y a cuál va cada sensor.
+func newQueues(stage string, cfg BrokerConfig) ([]*queue, map[SensorKind]*queue, error) {
+	var queues []*queue
+	routes := make(map[SensorKind]*queue)
+	var fallback *queue
+	names := make(map[string]bool)
+	for _, qc := range cfg.Queues {
+		if qc.Name == "" {
+			return nil, nil, fmt.Errorf("toda cola del broker necesita nombre")
+		}
+		if names[qc.Name] {
+			return nil, nil, fmt.Errorf("cola '%s' repetida", qc.Name)
+		}
+		names[qc.Name] = true
+		if qc.NackRate < 0 || qc.NackRate >= 1 {
+			return nil, nil, fmt.Errorf("cola '%s': nack_rate debe estar en [0, 1)", qc.Name)
+		}
+		if qc.ProcessMs < 0 {
+			return nil, nil, fmt.Errorf("cola '%s': process_ms no puede ser negativo", qc.Name)
+		}
+		if qc.Consumers < 1 {
+			qc.Consumers = 1
+		}
+		if qc.Prefetch < 1 {
+			qc.Prefetch = 1
+		}
+		q := &queue{cfg: qc, stats: QueueStats{Stage: stage, Name: qc.Name, Capacity: qc.Capacity}}
+		for i := 0; i < qc.Consumers; i++ {
+			q.consumers = append(q.consumers, &consumer{})
+		}
+		queues = append(queues, q)
+
+		if len(qc.Sensors) == 0 && fallback == nil {
+			fallback = q
+		}
+		for _, kind := range qc.Sensors {
+			if _, ok := routes[kind]; ok {
+				return nil, nil, fmt.Errorf("el sensor '%s' está en más de una cola", kind)
+			}
+			routes[kind] = q
+		}
+	}
+	for _, kind := range SensorKinds {
+		if _, ok := routes[kind]; ok {
+			continue
+		}
+		if fallback == nil {
+			return nil, nil, fmt.Errorf("el sensor '%s' no tiene cola en el broker", kind)
+		}
+		routes[kind] = fallback
+	}
+	return queues, routes, nil
+}
+
+// QueueFor retorna el nombre de la cola de stage donde se publica el sensor.
+func (b *Broker) QueueFor(stage string, kind SensorKind) string {
+	if q, ok := b.routes[stage][kind]; ok {
+		return q.cfg.Name
+	}
+	return ""
+}
+
+// Publish encola el mensaje id del sensor en su cola de stage. Retorna
+// ErrQueueFull si la cola no tiene lugar.
+func (b *Broker) Publish(stage string, kind SensorKind, id string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	q, ok := b.routes[stage][kind]
+	if !ok {
+		return fmt.Errorf("sensor '%s' sin cola en '%s'", kind, stage)
+	}
+	if q.cfg.Capacity > 0 && len(q.ready) >= q.cfg.Capacity {
+		q.stats.Rejected++
+		return ErrQueueFull
+	}
+	q.ready = append(q.ready, id)
+	q.stats.Published++
+	q.dispatch()
+	return nil
+}
+
+// Tick avanza el procesamiento dt y retorna los IDs confirmados (ack) en
+// este paso, en orden.
+func (b *Broker) Tick(dt time.Duration) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var acked []string
+	for _, q := range b.queues {
+		for _, c := range q.consumers {
+			if len(c.unacked) == 0 {
+				continue
+			}
+			c.remaining -= dt
+			// En un tick largo (a 4× o con process_ms menor que un tick) el
+			// consumidor puede terminar varios mensajes; lo que sobra del
+			// tick pasa al siguiente.
+			for len(c.unacked) > 0 && c.remaining <= 0 {
+				d := c.unacked[0]
+				c.unacked = c.unacked[1:]
+				if q.cfg.NackRate > 0 && b.rng.Float64() < q.cfg.NackRate {
+					q.nack(d)
+				} else {
+					q.stats.Acked++
+					acked = append(acked, d)
+				}
+				leftover := c.remaining
+				q.dispatch()
+				if len(c.unacked) > 0 {
+					c.remaining = leftover + q.processTime()
+				} else {
+					c.remaining = 0
+				}
+			}
+		}
+		q.dispatch()
+	}
+	return acked
+}
+
+// Busy indica si algún consumidor de stage está procesando.
+func (b *Broker) Busy(stage string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, q := range b.queues {
+		if q.stats.Stage != stage {
+			continue
+		}
+		for _, c := range q.consumers {
+			if len(c.unacked) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Stats retorna una foto de cada cola de stage, ordenadas por nombre.
+func (b *Broker) Stats(stage string) []QueueStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var out []QueueStats
+	for _, q := range b.queues {
+		if q.stats.Stage != stage {
+			continue
+		}
+		s := q.stats
+		s.Ready = len(q.ready)
+		for _, c := range q.consumers {
+			s.Unacked += len(c.unacked)
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Reset vacía las colas y los contadores.
+func (b *Broker) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, q := range b.queues {
+		q.ready = nil
+		q.stats = QueueStats{Stage: q.stats.Stage, Name: q.cfg.Name, Capacity: q.cfg.Capacity}
+		for _, c := range q.consumers {
+			c.unacked, c.remaining = nil, 0
+		}
+	}
+}
+
+// dispatch entrega mensajes listos a los consumidores con lugar en su
+// prefetch, en ronda.
+func (q *queue) dispatch() {
+	for len(q.ready) > 0 {
+		var target *consumer
+		for _, c := range q.consumers {
+			if len(c.unacked) < q.cfg.Prefetch && (target == nil || len(c.unacked) < len(target.unacked)) {
+				target = c
+			}
+		}
+		if target == nil {
+			return
+		}
+		if len(target.unacked) == 0 {
+			target.remaining = q.processTime()
+		}
+		target.unacked = append(target.unacked, q.ready[0])
+		q.ready = q.ready[1:]
+	}
+}
+
+// nack devuelve el mensaje al principio de la cola, como hace RabbitMQ con
+// requeue=true.
+func (q *queue) nack(id string) {
+	q.stats.Nacked++
+	q.ready = append([]string{id}, q.ready...)
+}
+
+func (q *queue) processTime() time.Duration {
+	return time.Duration(q.cfg.ProcessMs) * time.Millisecond
+}
@@ -0,0 +1,170 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestBroker(t *testing.T, q QueueConfig) *Broker {
+	t.Helper()
+	q.Name = "geova.test"
+	b, err := NewBroker(BrokerConfig{Queues: []QueueConfig{q}}, []string{"cola"}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func publishN(t *testing.T, b *Broker, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := b.Publish("cola", SensorTFLuna, fmt.Sprint(i)); err != nil {
+			t.Fatalf("Publish %d: %v", i, err)
+		}
+	}
+}
+
+func TestBrokerAcks(t *testing.T) {
+	tick := time.Second / 60
+	tests := []struct {
+		name  string
+		queue QueueConfig
+		ticks int
+		want  [][]string // acks por tick
+	}{
+		{
+			name:  "un mensaje cada process_ms",
+			queue: QueueConfig{Consumers: 1, Prefetch: 1, ProcessMs: 30},
+			ticks: 4,
+			want:  [][]string{nil, {"0"}, nil, {"1"}},
+		},
+		{
+			name:  "process_ms menor que un tick",
+			queue: QueueConfig{Consumers: 1, Prefetch: 1, ProcessMs: 5},
+			ticks: 2,
+			want:  [][]string{{"0", "1", "2"}, {"3", "4", "5"}},
+		},
+		{
+			name:  "dos consumidores en paralelo",
+			queue: QueueConfig{Consumers: 2, Prefetch: 1, ProcessMs: 10},
+			ticks: 1,
+			want:  [][]string{{"0", "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBroker(t, tt.queue)
+			publishN(t, b, 6)
+			for i := 0; i < tt.ticks; i++ {
+				if got := b.Tick(tick); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("tick %d: acks %v, se esperaba %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A 4× un tick dura cuatro veces más y rinde lo mismo que cuatro ticks a 1×.
+func TestBrokerTickCarriesLeftover(t *testing.T) {
+	slow := newTestBroker(t, QueueConfig{ProcessMs: 25})
+	fast := newTestBroker(t, QueueConfig{ProcessMs: 25})
+	publishN(t, slow, 20)
+	publishN(t, fast, 20)
+
+	n := 0
+	for i := 0; i < 12; i++ {
+		n += len(slow.Tick(10 * time.Millisecond))
+	}
+	m := 0
+	for i := 0; i < 3; i++ {
+		m += len(fast.Tick(40 * time.Millisecond))
+	}
+	if n != 4 || m != 4 {
+		t.Errorf("120ms a 25ms por mensaje: %d acks en ticks de 10ms y %d en ticks de 40ms, se esperaban 4", n, m)
+	}
+}
+
+func TestBrokerPrefetchAndCapacity(t *testing.T) {
+	b := newTestBroker(t, QueueConfig{Capacity: 3, Consumers: 1, Prefetch: 2, ProcessMs: 100})
+	publishN(t, b, 5)
+	if err := b.Publish("cola", SensorTFLuna, "x"); err != ErrQueueFull {
+		t.Fatalf("Publish con la cola llena = %v, se esperaba ErrQueueFull", err)
+	}
+	s := b.Stats("cola")[0]
+	if s.Unacked != 2 || s.Ready != 3 || s.Rejected != 1 {
+		t.Errorf("Stats = %+v, se esperaban 2 sin ack, 3 listos y 1 rechazado", s)
+	}
+}
+
+func TestBrokerNackRequeues(t *testing.T) {
+	b := newTestBroker(t, QueueConfig{ProcessMs: 10, NackRate: 0.5})
+	publishN(t, b, 10)
+
+	var acked []string
+	for i := 0; i < 1000 && len(acked) < 10; i++ {
+		acked = append(acked, b.Tick(10*time.Millisecond)...)
+	}
+	s := b.Stats("cola")[0]
+	if len(acked) != 10 || s.Acked != 10 || s.Nacked == 0 {
+		t.Fatalf("acks %d (%d en Stats), nacks %d; se esperaban 10 acks y algún nack", len(acked), s.Acked, s.Nacked)
+	}
+	// Con requeue el mensaje rechazado vuelve al principio: el orden se
+	// conserva con un solo consumidor.
+	for i, id := range acked {
+		if id != fmt.Sprint(i) {
+			t.Fatalf("orden de acks %v", acked)
+		}
+	}
+}
+
+func TestBrokerQueuesPerStage(t *testing.T) {
+	b, err := NewBroker(BrokerConfig{Queues: []QueueConfig{{Name: "geova.test", Capacity: 1, ProcessMs: 100}}},
+		[]string{"a", "b"}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stage := range []string{"a", "b"} {
+		// Uno lo toma el consumidor y el otro queda listo.
+		for i := 0; i < 2; i++ {
+			if err := b.Publish(stage, SensorMPU, stage+fmt.Sprint(i)); err != nil {
+				t.Fatalf("Publish en %s: %v", stage, err)
+			}
+		}
+	}
+	if err := b.Publish("a", SensorMPU, "x"); err != ErrQueueFull {
+		t.Errorf("Publish con la cola de 'a' llena = %v", err)
+	}
+	if err := b.Publish("c", SensorMPU, "x"); err == nil {
+		t.Error("Publish en una etapa sin colas no retornó error")
+	}
+	for _, stage := range []string{"a", "b"} {
+		s := b.Stats(stage)
+		if len(s) != 1 || s[0].Stage != stage || s[0].Ready != 1 || s[0].Unacked != 1 {
+			t.Errorf("Stats(%s) = %+v", stage, s)
+		}
+	}
+}
+
+func TestNewBrokerValidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		queues []QueueConfig
+	}{
+		{"sin nombre", []QueueConfig{{}}},
+		{"nombre repetido", []QueueConfig{{Name: "a"}, {Name: "a"}}},
+		{"sensor en dos colas", []QueueConfig{{Name: "a", Sensors: []SensorKind{SensorMPU}}, {Name: "b", Sensors: []SensorKind{SensorMPU}}}},
+		{"sensor sin cola", []QueueConfig{{Name: "a", Sensors: []SensorKind{SensorMPU}}}},
+		{"nack_rate 1", []QueueConfig{{Name: "a", NackRate: 1}}},
+		{"process_ms negativo", []QueueConfig{{Name: "a", ProcessMs: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBroker(BrokerConfig{Queues: tt.queues}, nil, rand.New(rand.NewSource(1))); err == nil {
+				t.Error("NewBroker no retornó error")
+			}
+		})
+	}
+}
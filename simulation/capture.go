@@ -0,0 +1,252 @@
+package simulation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptureConfig reemplaza el modelo de un sensor por lecturas reales
+// capturadas en campo. Las columnas (CSV) o claves (JSONL) son los tags JSON
+// de datatypes.go; las que falten quedan en cero.
+type CaptureConfig struct {
+	Path string `json:"path"`
+	// Format es "csv" o "jsonl"; vacío = según la extensión de Path.
+	Format string `json:"format"`
+	// Loop vuelve al principio al terminar el archivo. Si es false, el
+	// dispositivo deja de enviar al agotarse cualquiera de sus capturas.
+	Loop bool `json:"loop"`
+	// RewriteTimestamp reemplaza el timestamp capturado por el del envío.
+	RewriteTimestamp bool `json:"rewrite_timestamp"`
+}
+
+// Capture son las lecturas ya decodificadas de un archivo. Es de sólo
+// lectura: cada dispositivo la recorre con su propio cursor.
+type Capture struct {
+	Kind    SensorKind
+	Config  CaptureConfig
+	records []interface{}
+}
+
+// Len es la cantidad de lecturas.
+func (c *Capture) Len() int {
+	return len(c.records)
+}
+
+// LoadCaptures lee los archivos configurados en cada proyecto. Se llama una
+// vez al arrancar para que un archivo inválido se reporte antes de simular.
+func LoadCaptures(projects []ProjectConfig) error {
+	cache := make(map[string]*Capture)
+	for i := range projects {
+		p := &projects[i]
+		for kind, cfg := range p.Captures {
+			if cfg.Path == "" {
+				continue
+			}
+			key := string(kind) + "|" + cfg.Path
+			c, ok := cache[key]
+			if !ok {
+				var err error
+				if c, err = LoadCapture(kind, cfg); err != nil {
+					return err
+				}
+				cache[key] = c
+				fmt.Printf("[CAPTURA] %s: %d lecturas desde %s\n", kind, c.Len(), cfg.Path)
+			}
+			if p.captures == nil {
+				p.captures = make(map[SensorKind]*Capture)
+			}
+			// La copia conserva las opciones propias del proyecto aunque el
+			// archivo se comparta.
+			shared := *c
+			shared.Config = cfg
+			p.captures[kind] = &shared
+		}
+	}
+	return nil
+}
+
+// LoadCapture decodifica todo el archivo de un sensor.
+func LoadCapture(kind SensorKind, cfg CaptureConfig) (*Capture, error) {
+	if _, err := newReading(kind); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la captura '%s': %w", cfg.Path, err)
+	}
+
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(cfg.Path)), ".")
+	}
+
+	c := &Capture{Kind: kind, Config: cfg}
+	switch format {
+	case "csv":
+		err = c.parseCSV(data)
+	case "jsonl", "ndjson":
+		err = c.parseJSONL(data)
+	default:
+		return nil, fmt.Errorf("formato de captura desconocido '%s' en '%s' (csv o jsonl)", format, cfg.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("captura '%s': %w", cfg.Path, err)
+	}
+	if len(c.records) == 0 {
+		return nil, fmt.Errorf("la captura '%s' no tiene lecturas", cfg.Path)
+	}
+	return c, nil
+}
+
+func (c *Capture) parseJSONL(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := c.add(scanner.Bytes()); err != nil {
+			return fmt.Errorf("línea %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseCSV convierte cada fila en un objeto JSON usando el tipo del campo
+// con ese tag, así CSV y JSONL pasan por el mismo decodificador.
+func (c *Capture) parseCSV(data []byte) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("sin encabezado: %w", err)
+	}
+
+	sample, _ := newReading(c.Kind)
+	kinds := jsonFieldKinds(reflect.TypeOf(sample).Elem())
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if _, ok := kinds[header[i]]; !ok {
+			return fmt.Errorf("columna desconocida '%s' para %s", header[i], c.Kind)
+		}
+	}
+
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		obj := make(map[string]interface{}, len(row))
+		for i, cell := range row {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			v, err := parseCell(kinds[header[i]], cell)
+			if err != nil {
+				return fmt.Errorf("línea %d, columna '%s': %w", line, header[i], err)
+			}
+			obj[header[i]] = v
+		}
+		raw, _ := json.Marshal(obj)
+		if err := c.add(raw); err != nil {
+			return fmt.Errorf("línea %d: %w", line, err)
+		}
+	}
+}
+
+func (c *Capture) add(raw []byte) error {
+	ptr, _ := newReading(c.Kind)
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ptr); err != nil {
+		return err
+	}
+	c.records = append(c.records, reflect.ValueOf(ptr).Elem().Interface())
+	return nil
+}
+
+// newReading retorna un puntero a la lectura vacía del sensor.
+func newReading(kind SensorKind) (interface{}, error) {
+	switch kind {
+	case SensorTFLuna:
+		return &TFLunaData{}, nil
+	case SensorMPU:
+		return &MPUData{}, nil
+	case SensorIMX:
+		return &IMXData{}, nil
+	}
+	return nil, fmt.Errorf("sensor desconocido '%s'", kind)
+}
+
+func jsonFieldKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			kinds[name] = f.Type.Kind()
+		}
+	}
+	return kinds
+}
+
+func parseCell(kind reflect.Kind, cell string) (interface{}, error) {
+	switch kind {
+	case reflect.Bool:
+		return strconv.ParseBool(cell)
+	case reflect.Int:
+		return strconv.Atoi(cell)
+	case reflect.Float64:
+		return strconv.ParseFloat(cell, 64)
+	}
+	return cell, nil
+}
+
+// captureCursor recorre una captura para un dispositivo.
+type captureCursor struct {
+	capture *Capture
+	pos     int
+}
+
+// next retorna la siguiente lectura o io.EOF si terminó y no hay Loop.
+func (cc *captureCursor) next(now time.Time) (interface{}, error) {
+	if cc.pos >= len(cc.capture.records) {
+		if !cc.capture.Config.Loop {
+			return nil, io.EOF
+		}
+		cc.pos = 0
+	}
+	r := cc.capture.records[cc.pos]
+	cc.pos++
+
+	if !cc.capture.Config.RewriteTimestamp {
+		return r, nil
+	}
+	ts := now.Format(TimestampLayout)
+	switch v := r.(type) {
+	case TFLunaData:
+		v.Timestamp = ts
+		return v, nil
+	case MPUData:
+		v.Timestamp = ts
+		return v, nil
+	case IMXData:
+		v.Timestamp = ts
+		return v, nil
+	}
+	return r, nil
+}
@@ -0,0 +1,121 @@
+package simulation
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeCapture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCaptureRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string // fragmento del error
+	}{
+		{"clave desconocida en jsonl", "a.jsonl", `{"distancia_cm": 120}` + "\n" + `{"distancia": 1.2}`, `línea 2: json: unknown field "distancia"`},
+		{"jsonl inválido", "a.jsonl", `{"distancia_cm": }`, "línea 1"},
+		{"columna desconocida", "a.csv", "distancia_cm,distancia\n120,1.2\n", "columna desconocida 'distancia' para tfluna"},
+		{"celda con otro tipo", "a.csv", "distancia_cm,event\n120,true\nmucho,true\n", "línea 3, columna 'distancia_cm'"},
+		{"fila con columnas de más", "a.csv", "distancia_cm\n120\n130,1\n", "wrong number of fields"},
+		{"csv vacío", "a.csv", "", "sin encabezado"},
+		{"sin lecturas", "a.jsonl", "\n\n", "no tiene lecturas"},
+		{"formato desconocido", "a.txt", "120", "formato de captura desconocido 'txt'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeCapture(t, tt.file, tt.content)
+			_, err := LoadCapture(SensorTFLuna, CaptureConfig{Path: path})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadCapture = %v, se esperaba un error con %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCaptureCSVAndJSONLMatch(t *testing.T) {
+	csvPath := writeCapture(t, "a.csv", "distancia_cm, distancia_m,fuerza_senal,event,timestamp\n120,1.2,900,true,2024-05-01 10:00:00\n")
+	jsonlPath := writeCapture(t, "a.jsonl", `{"distancia_cm":120,"distancia_m":1.2,"fuerza_senal":900,"event":true,"timestamp":"2024-05-01 10:00:00"}`+"\n")
+
+	fromCSV, err := LoadCapture(SensorTFLuna, CaptureConfig{Path: csvPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSONL, err := LoadCapture(SensorTFLuna, CaptureConfig{Path: jsonlPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromCSV.records[0] != fromJSONL.records[0] {
+		t.Errorf("CSV %+v, JSONL %+v", fromCSV.records[0], fromJSONL.records[0])
+	}
+}
+
+func TestCaptureCursor(t *testing.T) {
+	path := writeCapture(t, "a.jsonl", `{"distancia_cm":1,"timestamp":"x"}`+"\n"+`{"distancia_cm":2,"timestamp":"x"}`+"\n")
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		cfg  CaptureConfig
+		want []int // distancia_cm por lectura; 0 = io.EOF
+	}{
+		{"termina sin loop", CaptureConfig{}, []int{1, 2, 0, 0}},
+		{"vuelve al principio con loop", CaptureConfig{Loop: true}, []int{1, 2, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Path = path
+			c, err := LoadCapture(SensorTFLuna, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cc := &captureCursor{capture: c}
+			for i, want := range tt.want {
+				r, err := cc.next(now)
+				if want == 0 {
+					if err != io.EOF {
+						t.Fatalf("lectura %d: %v, %v; se esperaba io.EOF", i, r, err)
+					}
+					continue
+				}
+				if err != nil || r.(TFLunaData).DistanciaCm != want {
+					t.Fatalf("lectura %d: %v, %v; se esperaba distancia_cm %d", i, r, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCaptureRewriteTimestamp(t *testing.T) {
+	path := writeCapture(t, "a.jsonl", `{"distancia_cm":1,"timestamp":"2020-01-01 00:00:00"}`+"\n")
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, rewrite := range []bool{false, true} {
+		c, err := LoadCapture(SensorTFLuna, CaptureConfig{Path: path, RewriteTimestamp: rewrite})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := (&captureCursor{capture: c}).next(now)
+		want := "2020-01-01 00:00:00"
+		if rewrite {
+			want = now.Format(TimestampLayout)
+		}
+		if got := r.(TFLunaData).Timestamp; got != want {
+			t.Errorf("rewrite_timestamp=%v: timestamp %q, se esperaba %q", rewrite, got, want)
+		}
+		// La captura no cambia: otro cursor ve el timestamp original.
+		if got := c.records[0].(TFLunaData).Timestamp; got != "2020-01-01 00:00:00" {
+			t.Errorf("la captura quedó con timestamp %q", got)
+		}
+	}
+}
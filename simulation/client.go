@@ -0,0 +1,73 @@
+package simulation
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPClientConfig ajusta el cliente HTTP compartido por todos los workers.
+// Los tiempos están en milisegundos.
+type HTTPClientConfig struct {
+	TimeoutMs           int `json:"timeout_ms"`
+	DialTimeoutMs       int `json:"dial_timeout_ms"`
+	KeepAliveMs         int `json:"keep_alive_ms"`
+	IdleConnTimeoutMs   int `json:"idle_conn_timeout_ms"`
+	MaxIdleConns        int `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int `json:"max_conns_per_host"`
+}
+
+// DefaultHTTPClientConfig es suficiente para unos pocos sensores contra una
+// sola API; las cargas más grandes deberían subir los límites del pool.
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		TimeoutMs:           10000,
+		DialTimeoutMs:       3000,
+		KeepAliveMs:         30000,
+		IdleConnTimeoutMs:   90000,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		MaxConnsPerHost:     32,
+	}
+}
+
+func ms(v int) time.Duration {
+	return time.Duration(v) * time.Millisecond
+}
+
+// NewHTTPClient crea el cliente que comparten todos los SendPOSTRequest.
+func NewHTTPClient(cfg HTTPClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   ms(cfg.DialTimeoutMs),
+		KeepAlive: ms(cfg.KeepAliveMs),
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       ms(cfg.IdleConnTimeoutMs),
+		TLSHandshakeTimeout:   ms(cfg.DialTimeoutMs),
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   ms(cfg.TimeoutMs),
+	}
+}
+
+// sleepCtx duerme d o hasta que ctx se cancele, en cuyo caso retorna ctx.Err().
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
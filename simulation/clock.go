@@ -0,0 +1,179 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Speeds son las velocidades que ofrece la ventana, de menor a mayor.
+var Speeds = []float64{0, 0.25, 0.5, 1, 2, 4}
+
+// ValidSpeed retorna error si s no está en Speeds.
+func ValidSpeed(s float64) error {
+	for _, v := range Speeds {
+		if v == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("velocidad %g inválida (0, 0.25, 0.5, 1, 2 o 4)", s)
+}
+
+// wallEpoch es el origen de Now para un Clock nil.
+var wallEpoch = time.Now()
+
+// Clock es el reloj de la simulación. Lo avanza la FSM en cada tick con
+// Advance (el tiempo real del tick por la velocidad), y los generadores y
+// workers esperan sobre él con Sleep, así que pausar o acelerar afecta a
+// todo por igual. Las peticiones HTTP siguen en tiempo real.
+//
+// Un Clock nil es el reloj de pared a 1×.
+type Clock struct {
+	mutex   sync.Mutex
+	now     time.Duration // tiempo simulado desde NewClock
+	speed   float64
+	resume  float64 // velocidad a la que vuelve Toggle tras una pausa
+	step    bool    // avanzar un tick estando en pausa
+	waiters []clockWaiter
+}
+
+type clockWaiter struct {
+	at   time.Duration
+	done chan struct{}
+}
+
+// NewClock crea un reloj a 1×.
+func NewClock() *Clock {
+	return &Clock{speed: 1, resume: 1}
+}
+
+// Speed retorna la velocidad actual (0 = en pausa).
+func (c *Clock) Speed() float64 {
+	if c == nil {
+		return 1
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.speed
+}
+
+// SetSpeed cambia la velocidad.
+func (c *Clock) SetSpeed(s float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.setSpeed(s)
+}
+
+func (c *Clock) setSpeed(s float64) {
+	if c.speed > 0 {
+		c.resume = c.speed
+	}
+	c.speed = s
+}
+
+// Faster y Slower pasan a la velocidad siguiente o anterior de Speeds y la
+// retornan.
+func (c *Clock) Faster() float64 { return c.shift(1) }
+func (c *Clock) Slower() float64 { return c.shift(-1) }
+
+func (c *Clock) shift(dir int) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	i := 0
+	for i < len(Speeds)-1 && Speeds[i] < c.speed {
+		i++
+	}
+	i += dir
+	if i >= 0 && i < len(Speeds) {
+		c.setSpeed(Speeds[i])
+	}
+	return c.speed
+}
+
+// Toggle pausa, o retoma a la velocidad que tenía antes de la pausa.
+func (c *Clock) Toggle() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.speed > 0 {
+		c.setSpeed(0)
+	} else {
+		c.speed = c.resume
+	}
+	return c.speed
+}
+
+// Step pide que el próximo Advance avance un tick a 1× aunque esté en
+// pausa.
+func (c *Clock) Step() {
+	c.mutex.Lock()
+	c.step = true
+	c.mutex.Unlock()
+}
+
+// Advance adelanta el reloj real·velocidad (o real si hay un Step
+// pendiente en pausa), despierta a los Sleep vencidos y retorna cuánto
+// avanzó.
+func (c *Clock) Advance(real time.Duration) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	dt := time.Duration(float64(real) * c.speed)
+	if c.speed == 0 && c.step {
+		dt = real
+	}
+	c.step = false
+	if dt == 0 {
+		return 0
+	}
+	c.now += dt
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at <= c.now {
+			close(w.done)
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+	return dt
+}
+
+// Now es el tiempo simulado desde que se creó el reloj.
+func (c *Clock) Now() time.Duration {
+	if c == nil {
+		return time.Since(wallEpoch)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep espera d de tiempo simulado o hasta que se cancele ctx.
+func (c *Clock) Sleep(ctx context.Context, d time.Duration) error {
+	if c == nil {
+		return sleepCtx(ctx, d)
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	c.mutex.Lock()
+	w := clockWaiter{at: c.now + d, done: make(chan struct{})}
+	c.waiters = append(c.waiters, w)
+	c.mutex.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		c.mutex.Lock()
+		for i, other := range c.waiters {
+			if other.done == w.done {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				break
+			}
+		}
+		c.mutex.Unlock()
+		return ctx.Err()
+	}
+}
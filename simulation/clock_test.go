@@ -0,0 +1,146 @@
+package simulation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const testTick = time.Second / 60
+
+func TestClockAdvance(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *Clock)
+		want  time.Duration
+	}{
+		{"1×", func(c *Clock) {}, testTick},
+		{"0.25×", func(c *Clock) { c.SetSpeed(0.25) }, testTick / 4},
+		{"4×", func(c *Clock) { c.SetSpeed(4) }, 4 * testTick},
+		{"pausa", func(c *Clock) { c.Toggle() }, 0},
+		{"un tick en pausa", func(c *Clock) { c.Toggle(); c.Step() }, testTick},
+		{"step sin pausa no suma", func(c *Clock) { c.SetSpeed(2); c.Step() }, 2 * testTick},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClock()
+			tt.setup(c)
+			if got := c.Advance(testTick); got != tt.want {
+				t.Errorf("Advance = %v, se esperaba %v", got, tt.want)
+			}
+			if c.Now() != tt.want {
+				t.Errorf("Now = %v, se esperaba %v", c.Now(), tt.want)
+			}
+		})
+	}
+}
+
+func TestClockStepIsOneTick(t *testing.T) {
+	c := NewClock()
+	c.Toggle()
+	c.Step()
+	c.Advance(testTick)
+	if got := c.Advance(testTick); got != 0 {
+		t.Errorf("el segundo Advance tras un Step avanzó %v", got)
+	}
+}
+
+func TestClockSpeeds(t *testing.T) {
+	c := NewClock()
+	var got []float64
+	for i := 0; i < 4; i++ {
+		got = append(got, c.Faster())
+	}
+	for i := 0; i < 7; i++ {
+		got = append(got, c.Slower())
+	}
+	want := []float64{2, 4, 4, 4, 2, 1, 0.5, 0.25, 0, 0, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Faster/Slower = %v, se esperaba %v", got, want)
+		}
+	}
+	// Toggle retoma la última velocidad distinta de 0.
+	c.SetSpeed(2)
+	c.SetSpeed(0)
+	if s := c.Toggle(); s != 2 {
+		t.Errorf("Toggle retomó a %g×, se esperaba 2×", s)
+	}
+}
+
+func TestClockSleep(t *testing.T) {
+	c := NewClock()
+	c.SetSpeed(4)
+	done := make(chan error, 1)
+	go func() { done <- c.Sleep(context.Background(), 100*time.Millisecond) }()
+	waitWaiters(t, c, 1)
+
+	// 4 × 16.6ms por tick: vence en el segundo tick.
+	c.Advance(testTick)
+	select {
+	case <-done:
+		t.Fatal("Sleep terminó antes de tiempo")
+	default:
+	}
+	c.Advance(testTick)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// En pausa no vence nunca; cancelar lo libera y lo saca de la lista.
+	c.SetSpeed(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- c.Sleep(ctx, time.Millisecond) }()
+	waitWaiters(t, c, 1)
+	c.Advance(time.Hour)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Sleep cancelado = %v", err)
+	}
+	waitWaiters(t, c, 0)
+
+	if err := c.Sleep(context.Background(), 0); err != nil {
+		t.Errorf("Sleep(0) = %v", err)
+	}
+}
+
+func TestNilClock(t *testing.T) {
+	var c *Clock
+	if c.Speed() != 1 {
+		t.Errorf("un Clock nil va a %g×", c.Speed())
+	}
+	if err := c.Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep = %v", err)
+	}
+}
+
+func TestValidSpeed(t *testing.T) {
+	for _, s := range Speeds {
+		if err := ValidSpeed(s); err != nil {
+			t.Errorf("ValidSpeed(%g) = %v", s, err)
+		}
+	}
+	for _, s := range []float64{-1, 0.1, 3, 8} {
+		if ValidSpeed(s) == nil {
+			t.Errorf("ValidSpeed(%g) aceptó una velocidad que no está en Speeds", s)
+		}
+	}
+}
+
+// waitWaiters espera a que haya n Sleep pendientes en c.
+func waitWaiters(t *testing.T, c *Clock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mutex.Lock()
+		got := len(c.waiters)
+		c.mutex.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d Sleep pendientes, se esperaban %d", got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
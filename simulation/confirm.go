@@ -0,0 +1,318 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"geova-simulation/state"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConfirmConfig activa la confirmación de punta a punta: un paquete sólo
+// llega a Done cuando su lectura aparece en el broadcast del WebSocket.
+type ConfirmConfig struct {
+	Enabled bool `json:"enabled"`
+	// URL del endpoint de broadcast. Vacía = el stand-in de la API mock si
+	// está activa, o ws://localhost:8000/ws.
+	URL string `json:"url"`
+	// TimeoutMs es cuánto espera un paquete en el frontend antes de darse
+	// por perdido.
+	TimeoutMs int `json:"timeout_ms"`
+}
+
+// DefaultConfirmConfig deja la confirmación apagada.
+func DefaultConfirmConfig() ConfirmConfig {
+	return ConfirmConfig{TimeoutMs: 10000}
+}
+
+// Timeout es TimeoutMs como duración.
+func (c ConfirmConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutMs) * time.Millisecond
+}
+
+// Confirmer escucha el broadcast del backend y correlaciona cada mensaje con
+// el paquete que lo originó: por su request_id si el backend lo reenvía, o
+// si no comparando la lectura completa (sensor + todos sus campos).
+// Implementa state.Observer para esperar cada paquete desde que la API lo
+// acepta (ArrivedAtAPI), que es cuando el backend lo difunde.
+type Confirmer struct {
+	URL     string
+	Timeout time.Duration
+	// Clock es el reloj con el que se descartan los mensajes sin paquete,
+	// el mismo con el que la FSM cuenta Timeout (nil = reloj de pared).
+	Clock *Clock
+
+	mutex     sync.Mutex
+	connected bool
+	expected  map[string][]string        // huella → IDs de paquete en orden
+	requests  map[string]string          // request_id → ID de paquete
+	confirmed map[string]bool            // ID de paquete → visto
+	early     map[string][]time.Duration // claves vistas antes de Expect (Clock.Now)
+	received  int
+}
+
+// NewConfirmer crea el cliente; Start lo conecta.
+func NewConfirmer(cfg ConfirmConfig) *Confirmer {
+	return &Confirmer{
+		URL:       cfg.URL,
+		Timeout:   cfg.Timeout(),
+		expected:  make(map[string][]string),
+		requests:  make(map[string]string),
+		confirmed: make(map[string]bool),
+		early:     make(map[string][]time.Duration),
+	}
+}
+
+// Start lee el broadcast hasta que ctx se cancele, reconectando si se corta.
+func (c *Confirmer) Start(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := c.listen(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("[WS] %v, reconectando en 2s\n", err)
+			}
+			sleepCtx(ctx, 2*time.Second)
+		}
+	}()
+}
+
+func (c *Confirmer) listen(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.URL, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a %s: %w", c.URL, err)
+	}
+	defer conn.Close()
+	// ReadMessage no recibe ctx: se cierra la conexión para destrabarlo.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	fmt.Printf("[WS] Conectado a %s\n", c.URL)
+	c.setConnected(true)
+	defer c.setConnected(false)
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("conexión cerrada: %w", err)
+		}
+		if fp, rid, ok := fingerprintMessage(msg); ok {
+			c.observe(fp, rid)
+		}
+	}
+}
+
+func (c *Confirmer) setConnected(v bool) {
+	c.mutex.Lock()
+	c.connected = v
+	c.mutex.Unlock()
+}
+
+// Connected indica si hay una conexión abierta.
+func (c *Confirmer) Connected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connected
+}
+
+// Expect registra la lectura de un paquete aceptado por la API, con su ID de
+// correlación (puede ser vacío).
+func (c *Confirmer) Expect(id, rid string, kind SensorKind, payload interface{}) {
+	fp, err := fingerprint(kind, payload)
+	if err != nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// El broadcast pudo llegar antes que el paquete a esta etapa.
+	if (rid != "" && c.takeEarly(requestKey(rid))) || c.takeEarly(fp) {
+		c.confirmed[id] = true
+		return
+	}
+	c.expected[fp] = append(c.expected[fp], id)
+	if rid != "" {
+		c.requests[rid] = id
+	}
+}
+
+// Transition espera el eco de los paquetes que la API acaba de aceptar.
+func (c *Confirmer) Transition(p *state.PacketState, from, to state.PacketStatus) {
+	if to == state.ArrivedAtAPI {
+		c.Expect(p.ID, p.CorrelationID, SensorKind(p.Sensor), p.Payload)
+	}
+}
+
+func (c *Confirmer) PacketCreated(*state.PacketState) {}
+
+func (c *Confirmer) AttemptDone(*state.PacketState, int, int, time.Duration, error) {}
+
+func (c *Confirmer) Phase(*state.PacketState, string, time.Time, time.Time) {}
+
+func (c *Confirmer) takeEarly(key string) bool {
+	seen := c.early[key]
+	if len(seen) == 0 {
+		return false
+	}
+	c.early[key] = seen[1:]
+	if len(c.early[key]) == 0 {
+		delete(c.early, key)
+	}
+	return true
+}
+
+func requestKey(rid string) string {
+	return "rid|" + rid
+}
+
+// Confirmed indica si el mensaje del paquete ya se vio.
+func (c *Confirmer) Confirmed(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.confirmed[id]
+}
+
+// Forget libera el paquete al terminar (Done o perdido).
+func (c *Confirmer) Forget(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.confirmed, id)
+	c.unexpect(id)
+}
+
+// unexpect quita id de las esperas pendientes.
+func (c *Confirmer) unexpect(id string) {
+	for rid, other := range c.requests {
+		if other == id {
+			delete(c.requests, rid)
+		}
+	}
+	for fp, ids := range c.expected {
+		for i, other := range ids {
+			if other == id {
+				c.expected[fp] = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(c.expected[fp]) == 0 {
+			delete(c.expected, fp)
+		}
+	}
+}
+
+// Reset descarta todo lo esperado y lo visto.
+func (c *Confirmer) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expected = make(map[string][]string)
+	c.requests = make(map[string]string)
+	c.confirmed = make(map[string]bool)
+	c.early = make(map[string][]time.Duration)
+	c.received = 0
+}
+
+// Received es la cantidad de mensajes reconocidos desde el último Reset.
+func (c *Confirmer) Received() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.received
+}
+
+func (c *Confirmer) observe(fp, rid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.received++
+
+	if id, ok := c.requests[rid]; ok && rid != "" {
+		c.confirmed[id] = true
+		c.unexpect(id)
+		return
+	}
+	// Un request_id desconocido puede venir de un backend que arma el suyo;
+	// la lectura completa sigue sirviendo para reconocerlo.
+	if ids := c.expected[fp]; len(ids) > 0 {
+		c.confirmed[ids[0]] = true
+		c.unexpect(ids[0])
+		return
+	}
+
+	// Puede ser de otro cliente o llegar antes que Expect; se guarda un
+	// rato y se descarta después.
+	key := fp
+	if rid != "" {
+		key = requestKey(rid)
+	}
+	now := c.Clock.Now()
+	c.early[key] = append(c.early[key], now)
+	for other, seen := range c.early {
+		for len(seen) > 0 && now-seen[0] > 2*c.Timeout {
+			seen = seen[1:]
+		}
+		if len(seen) == 0 {
+			delete(c.early, other)
+		} else {
+			c.early[other] = seen
+		}
+	}
+}
+
+// fingerprint serializa la lectura con su tipo concreto para que el orden y
+// formato de los campos no dependan de quién la generó.
+func fingerprint(kind SensorKind, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(kind) + "|" + string(data), nil
+}
+
+// fingerprintMessage acepta la lectura sola o envuelta en {"sensor": ...,
+// "request_id": ..., "data": {...}} (también "type"/"payload"), y reconoce
+// el sensor por sus campos si el mensaje no lo dice.
+func fingerprintMessage(msg []byte) (string, string, bool) {
+	var envelope struct {
+		Sensor    string          `json:"sensor"`
+		Type      string          `json:"type"`
+		RequestID string          `json:"request_id"`
+		Data      json.RawMessage `json:"data"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+	if json.Unmarshal(msg, &envelope) != nil {
+		return "", "", false
+	}
+	body := msg
+	switch {
+	case len(envelope.Data) > 0:
+		body = envelope.Data
+	case len(envelope.Payload) > 0:
+		body = envelope.Payload
+	}
+
+	kind := SensorKind(envelope.Sensor)
+	if kind == "" {
+		kind = SensorKind(envelope.Type)
+	}
+	if _, err := newReading(kind); err != nil {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			return "", "", false
+		}
+		switch {
+		case fields["distancia_cm"] != nil:
+			kind = SensorTFLuna
+		case fields["ax"] != nil:
+			kind = SensorMPU
+		case fields["nitidez_score"] != nil:
+			kind = SensorIMX
+		default:
+			return "", "", false
+		}
+	}
+
+	payload, err := decodePayload(kind, body)
+	if err != nil {
+		return "", "", false
+	}
+	fp, err := fingerprint(kind, payload)
+	return fp, envelope.RequestID, err == nil
+}
@@ -0,0 +1,27 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequestIDHeader es la cabecera con la que viaja el ID de correlación.
+const RequestIDHeader = "X-Request-ID"
+
+// CorrelationID liga una petición con el paquete en pantalla: corrida,
+// dispositivo, sensor y batch. Por ejemplo, "p4/tfluna_12" en la corrida
+// "3f9a1c" da "3f9a1c-p4-tfluna-12".
+func CorrelationID(run, packetID string, batch int) string {
+	base := packetID
+	if i := strings.LastIndex(base, "_"); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.ReplaceAll(base, "/", "-")
+	return fmt.Sprintf("%s-%s-%d", run, base, batch)
+}
+
+// runToken identifica la n-ésima corrida de un Runner. Sale de la semilla
+// para que las corridas con -seed repitan también sus IDs.
+func runToken(seed int64, n int) string {
+	return fmt.Sprintf("%06x", DeriveRand(seed, fmt.Sprintf("run/%d", n)).Uint32()&0xffffff)
+}
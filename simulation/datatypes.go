@@ -1,5 +1,11 @@
 package simulation
 
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
 type IMXData struct {
 	IDProject      int     `json:"id_project"`
 	Resolution     string  `json:"resolution"`
@@ -36,3 +42,81 @@ type TFLunaData struct {
 	Event       bool    `json:"event"`
 	Timestamp   string  `json:"timestamp"`
 }
+
+// TimestampLayout es el formato de fecha que espera la API.
+const TimestampLayout = "2006-01-02 15:04:05"
+
+func validateCommon(idProject int, timestamp string) error {
+	if idProject <= 0 {
+		return fmt.Errorf("id_project debe ser positivo (recibido %d)", idProject)
+	}
+	if _, err := time.Parse(TimestampLayout, timestamp); err != nil {
+		return fmt.Errorf("timestamp '%s' no coincide con el formato %s", timestamp, TimestampLayout)
+	}
+	return nil
+}
+
+func inRange(name string, v, min, max float64) error {
+	if math.IsNaN(v) || v < min || v > max {
+		return fmt.Errorf("%s fuera de rango [%g, %g]: %g", name, min, max, v)
+	}
+	return nil
+}
+
+// Validate revisa que la lectura tenga la forma que acepta la API.
+func (d IMXData) Validate() error {
+	if err := validateCommon(d.IDProject, d.Timestamp); err != nil {
+		return err
+	}
+	var w, h int
+	if n, _ := fmt.Sscanf(d.Resolution, "%dx%d", &w, &h); n != 2 || w <= 0 || h <= 0 {
+		return fmt.Errorf("resolution inválida '%s', se esperaba ANCHOxALTO", d.Resolution)
+	}
+	for _, check := range []error{
+		inRange("luminosidad_promedio", d.Luminosidad, 0, 255),
+		inRange("nitidez_score", d.Nitidez, 0, 100),
+		inRange("calidad_frame", d.CalidadFrame, 0, 100),
+		inRange("probabilidad_confiabilidad", d.Confiabilidad, 0, 1),
+	} {
+		if check != nil {
+			return check
+		}
+	}
+	return nil
+}
+
+// Validate revisa que la lectura tenga la forma que acepta la API.
+func (d MPUData) Validate() error {
+	if err := validateCommon(d.IDProject, d.Timestamp); err != nil {
+		return err
+	}
+	for _, check := range []error{
+		inRange("roll", d.Roll, -180, 180),
+		inRange("pitch", d.Pitch, -90, 90),
+		inRange("ax", d.Ax, -20, 20),
+		inRange("ay", d.Ay, -20, 20),
+		inRange("az", d.Az, -20, 20),
+	} {
+		if check != nil {
+			return check
+		}
+	}
+	return nil
+}
+
+// Validate revisa que la lectura tenga la forma que acepta la API.
+func (d TFLunaData) Validate() error {
+	if err := validateCommon(d.IDProject, d.Timestamp); err != nil {
+		return err
+	}
+	if d.DistanciaCm < 0 {
+		return fmt.Errorf("distancia_cm no puede ser negativa: %d", d.DistanciaCm)
+	}
+	if math.Abs(d.DistanciaM-float64(d.DistanciaCm)/100.0) > 0.01 {
+		return fmt.Errorf("distancia_m (%.2f) no corresponde con distancia_cm (%d)", d.DistanciaM, d.DistanciaCm)
+	}
+	if d.FuerzaSenal < 0 {
+		return fmt.Errorf("fuerza_senal no puede ser negativa: %d", d.FuerzaSenal)
+	}
+	return inRange("temperatura", d.Temperatura, -40, 125)
+}
@@ -0,0 +1,197 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ProjectConfig es un proyecto del backend con su propio trípode y su propia
+// configuración de sensores.
+type ProjectConfig struct {
+	ID       int          `json:"id"`
+	Name     string       `json:"name"`
+	DeviceID string       `json:"device_id"`
+	MPU      MPUConfig    `json:"mpu"`
+	TFLuna   TFLunaConfig `json:"tfluna"`
+	IMX      IMXConfig    `json:"imx477"`
+
+	// Captures reemplaza el modelo de los sensores indicados por lecturas
+	// de archivo; LoadCaptures las carga al arrancar.
+	Captures map[SensorKind]CaptureConfig `json:"captures"`
+	captures map[SensorKind]*Capture
+}
+
+// DefaultProject es el proyecto 4 que la simulación usó siempre.
+func DefaultProject() ProjectConfig {
+	return ProjectConfig{
+		ID:       4,
+		Name:     "Geova",
+		DeviceID: "geova-04",
+		MPU:      DefaultMPUConfig(),
+		TFLuna:   DefaultTFLunaConfig(),
+		IMX:      DefaultIMXConfig(),
+	}
+}
+
+// Device genera las lecturas de un proyecto. Cada dispositivo tiene sus
+// propios modelos, streams aleatorios y contador de batches, de modo que los
+// IDs de paquete de distintos proyectos no chocan.
+type Device struct {
+	Project ProjectConfig
+	ID      string
+
+	// Lane es el carril en pantalla; LaneY y Scale ubican su trípode.
+	Lane         int
+	LaneY, Scale float64
+	// Manual indica que su inclinación la controla el usuario con las flechas.
+	Manual bool
+	// Interval y Offset definen su propio calendario de envíos.
+	Interval, Offset time.Duration
+
+	namespace string
+	sway      sway
+	start     time.Time
+
+	seed    int64
+	streams map[SensorKind]*rand.Rand
+	mpu     *MPUModel
+	tfluna  *TFLunaModel
+	imx     *IMXModel
+	cursors map[SensorKind]*captureCursor
+
+	// Batch es el número del último batch generado.
+	Batch int
+}
+
+// SensorBatch son las tres lecturas de un mismo instante.
+type SensorBatch struct {
+	N      int
+	TFLuna TFLunaData
+	MPU    MPUData
+	IMX    IMXData
+}
+
+// NewDevice crea el dispositivo de un proyecto a partir de la semilla global.
+func NewDevice(p ProjectConfig, seed int64) *Device {
+	cursors := make(map[SensorKind]*captureCursor, len(p.captures))
+	for kind, c := range p.captures {
+		cursors[kind] = &captureCursor{capture: c}
+	}
+	return &Device{
+		Project:   p,
+		ID:        p.DeviceID,
+		Scale:     1,
+		namespace: fmt.Sprintf("p%d", p.ID),
+		seed:      seed,
+		streams:   make(map[SensorKind]*rand.Rand),
+		mpu:       NewMPUModel(p.MPU),
+		tfluna:    NewTFLunaModel(p.TFLuna),
+		imx:       NewIMXModel(p.IMX),
+		cursors:   cursors,
+	}
+}
+
+// Namespace prefija los IDs de paquete y de streams del dispositivo: "p4"
+// si es el único del proyecto, "p4-2" si el proyecto tiene varios.
+func (d *Device) Namespace() string {
+	return d.namespace
+}
+
+// Tilt retorna el roll y pitch del trípode automático en now.
+func (d *Device) Tilt(now time.Time) (float64, float64) {
+	if d.start.IsZero() {
+		d.start = now
+	}
+	return d.sway.at(now.Sub(d.start))
+}
+
+// seededNow es el instante del próximo batch contado desde SeededEpoch
+// según el calendario del dispositivo, para timestamps reproducibles.
+func (d *Device) seededNow() time.Time {
+	return SeededEpoch.Add(d.Offset + time.Duration(d.Batch)*d.Interval)
+}
+
+// PacketID arma el ID visible de un paquete, p. ej. "p4/tfluna_12".
+func (d *Device) PacketID(kind SensorKind, batch int) string {
+	short := string(kind)
+	if kind == SensorIMX {
+		short = "imx"
+	}
+	return fmt.Sprintf("%s/%s_%d", d.Namespace(), short, batch)
+}
+
+// Rand deriva un stream propio del dispositivo para name.
+func (d *Device) Rand(name string) *rand.Rand {
+	return DeriveRand(d.seed, d.Namespace()+"/"+name)
+}
+
+func (d *Device) stream(kind SensorKind) *rand.Rand {
+	rng, ok := d.streams[kind]
+	if !ok {
+		rng = d.Rand("sensor/" + string(kind))
+		d.streams[kind] = rng
+	}
+	return rng
+}
+
+// Sample genera el siguiente batch. La cámara depende de lo que midieron el
+// LiDAR y el IMU en el mismo instante, así que se muestrea al final. Los
+// sensores con captura toman la siguiente lectura del archivo; al agotarse
+// una captura sin Loop retorna io.EOF.
+func (d *Device) Sample(now time.Time, roll, pitch float64) (SensorBatch, error) {
+	var b SensorBatch
+	var scene CameraScene
+
+	if v, err := d.captured(SensorTFLuna, now); err != nil {
+		return b, err
+	} else if v != nil {
+		b.TFLuna = v.(TFLunaData)
+		scene.DistanceM = b.TFLuna.DistanciaM
+	} else {
+		b.TFLuna = d.tfluna.Sample(d.stream(SensorTFLuna), now)
+		scene.DistanceM = d.tfluna.LastTrueDistanceM
+	}
+
+	if v, err := d.captured(SensorMPU, now); err != nil {
+		return b, err
+	} else if v != nil {
+		b.MPU = v.(MPUData)
+		scene.RateDegPerS = math.Sqrt(b.MPU.Gx*b.MPU.Gx + b.MPU.Gy*b.MPU.Gy + b.MPU.Gz*b.MPU.Gz)
+	} else {
+		b.MPU = d.mpu.Sample(d.stream(SensorMPU), now, roll, pitch)
+		scene.RateDegPerS = d.mpu.LastRateDegPerS
+	}
+
+	if v, err := d.captured(SensorIMX, now); err != nil {
+		return b, err
+	} else if v != nil {
+		b.IMX = v.(IMXData)
+	} else {
+		b.IMX = d.imx.Sample(d.stream(SensorIMX), now, scene)
+	}
+
+	b.TFLuna.IDProject = d.Project.ID
+	b.MPU.IDProject = d.Project.ID
+	b.IMX.IDProject = d.Project.ID
+
+	d.Batch++
+	b.N = d.Batch
+	return b, nil
+}
+
+// Captured indica si el sensor sale de un archivo en vez del modelo.
+func (d *Device) Captured(kind SensorKind) bool {
+	return d.cursors[kind] != nil
+}
+
+// captured retorna la siguiente lectura capturada, o nil si el sensor usa
+// su modelo.
+func (d *Device) captured(kind SensorKind, now time.Time) (interface{}, error) {
+	cc, ok := d.cursors[kind]
+	if !ok {
+		return nil, nil
+	}
+	return cc.next(now)
+}
@@ -0,0 +1,54 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Distribution describe la distribución de una duración (latencia de la API
+// mock, procesamiento de una etapa). Todos los valores están en
+// milisegundos.
+//
+//	fixed:       siempre MeanMs
+//	uniform:     entre MinMs y MaxMs
+//	normal:      MeanMs ± StdDevMs (recortada a [MinMs, MaxMs] si MaxMs > 0)
+//	exponential: media MeanMs, desplazada por MinMs
+type Distribution struct {
+	Kind     string  `json:"kind"`
+	MeanMs   float64 `json:"mean_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+	MinMs    float64 `json:"min_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// Validate rechaza los tipos que Sample no conoce; vacío equivale a fixed.
+func (d Distribution) Validate() error {
+	switch d.Kind {
+	case "", "fixed", "uniform", "normal", "exponential":
+		return nil
+	}
+	return fmt.Errorf("distribución '%s' desconocida (fixed, uniform, normal o exponential)", d.Kind)
+}
+
+// Sample genera una duración usando rng.
+func (d Distribution) Sample(rng *rand.Rand) time.Duration {
+	var ms float64
+	switch d.Kind {
+	case "uniform":
+		ms = d.MinMs + rng.Float64()*(d.MaxMs-d.MinMs)
+	case "normal":
+		ms = d.MeanMs + rng.NormFloat64()*d.StdDevMs
+	case "exponential":
+		ms = d.MinMs + rng.ExpFloat64()*d.MeanMs
+	default:
+		ms = d.MeanMs
+	}
+
+	ms = math.Max(ms, d.MinMs)
+	if d.MaxMs > 0 {
+		ms = math.Min(ms, d.MaxMs)
+	}
+	return time.Duration(math.Max(ms, 0) * float64(time.Millisecond))
+}
@@ -0,0 +1,115 @@
+package simulation
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SensorKind identifica el tipo de sensor que origina un paquete.
+type SensorKind string
+
+const (
+	SensorTFLuna SensorKind = "tfluna"
+	SensorMPU    SensorKind = "mpu"
+	SensorIMX    SensorKind = "imx477"
+)
+
+// SensorKinds lista los sensores en el orden en que se envía cada batch.
+var SensorKinds = []SensorKind{SensorTFLuna, SensorMPU, SensorIMX}
+
+// Endpoint describe a dónde y cómo se envían los datos de un sensor.
+type Endpoint struct {
+	BaseURL string            `json:"base_url"`
+	Path    string            `json:"path"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Retry   RetryPolicy       `json:"retry"`
+
+	// NoDelay omite la demora visual de 500–1000ms antes de cada intento;
+	// el modo de carga la activa para medir sólo la API.
+	NoDelay bool `json:"-"`
+	// Clock marca la demora visual y las esperas entre intentos (nil = reloj
+	// de pared).
+	Clock *Clock `json:"-"`
+}
+
+// URL une BaseURL y Path sin duplicar ni perder la barra intermedia.
+func (e Endpoint) URL() string {
+	return strings.TrimRight(e.BaseURL, "/") + "/" + strings.TrimLeft(e.Path, "/")
+}
+
+// HTTPMethod retorna el método configurado o POST si no se especificó.
+func (e Endpoint) HTTPMethod() string {
+	if e.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(e.Method)
+}
+
+// merge sobreescribe los campos no vacíos de o sobre e.
+func (e Endpoint) merge(o Endpoint) Endpoint {
+	if o.BaseURL != "" {
+		e.BaseURL = o.BaseURL
+	}
+	if o.Path != "" {
+		e.Path = o.Path
+	}
+	if o.Method != "" {
+		e.Method = o.Method
+	}
+	if len(o.Headers) > 0 {
+		headers := make(map[string]string, len(e.Headers)+len(o.Headers))
+		for k, v := range e.Headers {
+			headers[k] = v
+		}
+		for k, v := range o.Headers {
+			headers[k] = v
+		}
+		e.Headers = headers
+	}
+	e.Retry = e.Retry.merge(o.Retry)
+	return e
+}
+
+// EndpointRegistry asocia cada sensor con su endpoint. Se arma una sola vez
+// al arrancar y después sólo se lee, por lo que no necesita Mutex.
+type EndpointRegistry map[SensorKind]Endpoint
+
+// DefaultEndpoints apunta a la API de Python corriendo en localhost:8000.
+func DefaultEndpoints() EndpointRegistry {
+	const base = "http://localhost:8000"
+	retry := DefaultRetryPolicy()
+	return EndpointRegistry{
+		SensorTFLuna: {BaseURL: base, Path: "/tfluna/sensor", Method: http.MethodPost, Retry: retry},
+		SensorMPU:    {BaseURL: base, Path: "/mpu/sensor", Method: http.MethodPost, Retry: retry},
+		SensorIMX:    {BaseURL: base, Path: "/imx477/sensor", Method: http.MethodPost, Retry: retry},
+	}
+}
+
+// Get retorna el endpoint del sensor indicado.
+func (r EndpointRegistry) Get(kind SensorKind) Endpoint {
+	return r[kind]
+}
+
+// Merge aplica sobre el registro los campos definidos en overrides.
+func (r EndpointRegistry) Merge(overrides EndpointRegistry) {
+	for kind, o := range overrides {
+		r[kind] = r[kind].merge(o)
+	}
+}
+
+// SetBaseURL cambia la URL base de todos los sensores.
+func (r EndpointRegistry) SetBaseURL(baseURL string) {
+	for kind, e := range r {
+		e.BaseURL = baseURL
+		r[kind] = e
+	}
+}
+
+// SetHeader agrega (o reemplaza) una cabecera en todos los sensores.
+func (r EndpointRegistry) SetHeader(name, value string) {
+	for kind, e := range r {
+		e = e.merge(Endpoint{Headers: map[string]string{name: value}})
+		r[kind] = e
+	}
+}
@@ -0,0 +1,209 @@
+package simulation
+
+import (
+	"bytes"
+	"fmt"
+	"geova-simulation/metrics"
+	"geova-simulation/state"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricsConfig expone las métricas del simulador para Prometheus.
+type MetricsConfig struct {
+	// Addr es donde escucha /metrics, p. ej. "127.0.0.1:9464" ("" = apagado).
+	Addr string `json:"addr"`
+}
+
+// MetricsPath es la ruta que sirve el Exporter.
+const MetricsPath = "/metrics"
+
+// Exporter cuenta paquetes y latencias como state.Observer y los sirve en
+// formato de texto de Prometheus. A diferencia de VisualState.Stats, sus
+// contadores no se reinician al detener y volver a iniciar la simulación.
+type Exporter struct {
+	State *state.VisualState
+	// Stages son los IDs de las etapas de la topología, para exportar su
+	// ocupación aunque estén vacías.
+	Stages []string
+
+	mutex     sync.Mutex
+	created   map[string]uint64    // sensor → paquetes
+	succeeded map[string]uint64    // sensor → paquetes que la API aceptó
+	failed    map[string]uint64    // sensor → paquetes que terminaron en Error
+	dropped   map[string]uint64    // sensor → paquetes descartados por backpressure
+	responses map[[2]string]uint64 // (sensor, código) → peticiones
+	latency   *metrics.LatencySet
+
+	http *http.Server
+}
+
+// NewExporter crea el exporter; Start lo pone a escuchar.
+func NewExporter(vs *state.VisualState) *Exporter {
+	return &Exporter{
+		State:     vs,
+		created:   make(map[string]uint64),
+		succeeded: make(map[string]uint64),
+		failed:    make(map[string]uint64),
+		dropped:   make(map[string]uint64),
+		responses: make(map[[2]string]uint64),
+		latency:   metrics.NewLatencySet(),
+	}
+}
+
+// Start escucha en addr y atiende /metrics en una goroutine. Retorna la URL
+// del endpoint.
+func (e *Exporter) Start(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("métricas: no se pudo escuchar en %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, e)
+	e.http = &http.Server{Handler: mux}
+
+	go func() {
+		if err := e.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("[MÉTRICAS] Error del servidor: %v\n", err)
+		}
+	}()
+	return "http://" + ln.Addr().String() + MetricsPath, nil
+}
+
+// Close detiene el servidor. Es seguro sobre nil.
+func (e *Exporter) Close() error {
+	if e == nil || e.http == nil {
+		return nil
+	}
+	return e.http.Close()
+}
+
+// PacketCreated, AttemptDone, Transition y Phase implementan state.Observer.
+func (e *Exporter) PacketCreated(p *state.PacketState) {
+	e.mutex.Lock()
+	e.created[p.Sensor]++
+	e.mutex.Unlock()
+}
+
+func (e *Exporter) AttemptDone(p *state.PacketState, attempt, statusCode int, latency time.Duration, err error) {
+	code := strconv.Itoa(statusCode)
+	if err != nil {
+		code = "error"
+	}
+	e.mutex.Lock()
+	e.responses[[2]string{p.Sensor, code}]++
+	e.mutex.Unlock()
+	e.latency.Record(p.Sensor, latency)
+}
+
+func (e *Exporter) Transition(p *state.PacketState, from, to state.PacketStatus) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	switch to {
+	case state.ArrivedAtAPI:
+		e.succeeded[p.Sensor]++
+	case state.Error:
+		e.failed[p.Sensor]++
+	case state.Dropped:
+		e.dropped[p.Sensor]++
+	}
+}
+
+func (e *Exporter) Phase(*state.PacketState, string, time.Time, time.Time) {}
+
+// ServeHTTP implementa http.Handler.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Los gauges salen del estado compartido; se toma una foto y se suelta
+	// Mutex antes de tomar el lock propio.
+	e.State.Mutex.Lock()
+	total := len(e.State.Packets)
+	statuses := make(map[state.PacketStatus]int)
+	occupancy := make(map[string]int)
+	inFlight := 0
+	for _, p := range e.State.Packets {
+		statuses[p.Status]++
+		if !p.Finished() {
+			inFlight++
+		}
+		if p.Status == state.ProcessingAtStage {
+			occupancy[p.Stage]++
+		}
+	}
+	waiting := make(map[string]int, len(e.State.StageLines))
+	for id, line := range e.State.StageLines {
+		waiting[id] = len(line)
+	}
+	load := e.State.Load
+	retired := e.State.Retired.Packets
+	e.State.Mutex.Unlock()
+
+	var buf bytes.Buffer
+
+	e.mutex.Lock()
+	writeCounter(&buf, "geova_packets_created_total", "Paquetes lanzados por los workers.", e.created)
+	writeCounter(&buf, "geova_packets_succeeded_total", "Paquetes aceptados por la API.", e.succeeded)
+	writeCounter(&buf, "geova_packets_failed_total", "Paquetes que terminaron en error.", e.failed)
+	writeCounter(&buf, "geova_packets_dropped_total", "Paquetes descartados por backpressure.", e.dropped)
+
+	metrics.WriteHeader(&buf, "geova_http_responses_total", "counter", "Peticiones HTTP por sensor y código (code=\"error\" si no hubo respuesta).")
+	keys := make([][2]string, 0, len(e.responses))
+	for k := range e.responses {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		metrics.WriteSample(&buf, "geova_http_responses_total", metrics.Labels{"sensor": k[0], "code": k[1]}, float64(e.responses[k]))
+	}
+	e.mutex.Unlock()
+
+	metrics.WriteHeader(&buf, "geova_http_request_duration_seconds", "histogram", "Latencia de cada petición HTTP por sensor.")
+	for _, s := range e.latency.Summaries() {
+		metrics.WriteHistogram(&buf, "geova_http_request_duration_seconds", metrics.Labels{"sensor": s.Name},
+			e.latency.Get(s.Name), metrics.DefaultBounds)
+	}
+
+	metrics.WriteHeader(&buf, "geova_packets", "gauge", "Paquetes en VisualState.Packets, terminados incluidos (acotado por la retención).")
+	metrics.WriteSample(&buf, "geova_packets", nil, float64(total))
+	metrics.WriteHeader(&buf, "geova_packets_retired", "gauge", "Paquetes terminados que la retención sacó de memoria en la corrida actual (vuelve a 0 al reiniciar).")
+	metrics.WriteSample(&buf, "geova_packets_retired", nil, float64(retired))
+	metrics.WriteHeader(&buf, "geova_packets_in_flight", "gauge", "Paquetes que todavía no terminaron.")
+	metrics.WriteSample(&buf, "geova_packets_in_flight", nil, float64(inFlight))
+	metrics.WriteHeader(&buf, "geova_stage_packets", "gauge", "Paquetes en cada estado de la FSM.")
+	for s := state.Idle; s <= state.Dropped; s++ {
+		metrics.WriteSample(&buf, "geova_stage_packets", metrics.Labels{"status": s.String()}, float64(statuses[s]))
+	}
+	metrics.WriteHeader(&buf, "geova_stage_occupancy", "gauge", "Paquetes retenidos en cada etapa de la topología.")
+	for _, id := range e.Stages {
+		metrics.WriteSample(&buf, "geova_stage_occupancy", metrics.Labels{"stage": id}, float64(occupancy[id]))
+	}
+	metrics.WriteHeader(&buf, "geova_stage_waiting", "gauge", "Paquetes en la fila de espera de cada etapa.")
+	for _, id := range e.Stages {
+		metrics.WriteSample(&buf, "geova_stage_waiting", metrics.Labels{"stage": id}, float64(waiting[id]))
+	}
+	metrics.WriteHeader(&buf, "geova_load_in_flight", "gauge", "Modo de carga: paquetes ocupando un lugar del límite de concurrencia.")
+	metrics.WriteSample(&buf, "geova_load_in_flight", nil, float64(load.InFlight))
+	metrics.WriteHeader(&buf, "geova_load_waiting", "gauge", "Modo de carga: paquetes esperando un lugar libre.")
+	metrics.WriteSample(&buf, "geova_load_waiting", nil, float64(load.Waiting))
+	metrics.WriteHeader(&buf, "geova_goroutines", "gauge", "Goroutines del proceso (una por paquete en curso, más las fijas).")
+	metrics.WriteSample(&buf, "geova_goroutines", nil, float64(runtime.NumGoroutine()))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func writeCounter(buf *bytes.Buffer, name, help string, bySensor map[string]uint64) {
+	metrics.WriteHeader(buf, name, "counter", help)
+	for _, kind := range SensorKinds {
+		metrics.WriteSample(buf, name, metrics.Labels{"sensor": string(kind)}, float64(bySensor[string(kind)]))
+	}
+}
@@ -0,0 +1,122 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FleetConfig define cuántos trípodes se simulan y cómo se comportan los que
+// no controla el usuario.
+type FleetConfig struct {
+	// Size es la cantidad total de dispositivos, repartidos en orden entre
+	// los proyectos (al menos uno por proyecto).
+	Size int `json:"size"`
+	// IntervalJitter varía el intervalo de envío de cada dispositivo
+	// automático en ±IntervalJitter (0 ≤ j < 1) y desfasa su primer envío.
+	// Con 0 todos los dispositivos reportan a la vez.
+	IntervalJitter float64 `json:"interval_jitter"`
+	// SwayDeg y SwayPeriodS describen el balanceo de los trípodes automáticos.
+	SwayDeg     float64 `json:"sway_deg"`
+	SwayPeriodS float64 `json:"sway_period_s"`
+}
+
+// DefaultFleetConfig es un único trípode, el que se mueve con las flechas.
+func DefaultFleetConfig() FleetConfig {
+	return FleetConfig{
+		Size:           1,
+		IntervalJitter: 0.2,
+		SwayDeg:        4,
+		SwayPeriodS:    20,
+	}
+}
+
+// Geometría de los carriles; coincide con tripodeX/tripodeY en game.
+const (
+	laneX      = 80.0
+	laneTop    = 75.0
+	laneBottom = 430.0
+	laneSprite = 128.0
+	singleY    = 200.0
+)
+
+// minDeviceInterval es el intervalo más corto que puede tocarle a un
+// dispositivo con el jitter.
+const minDeviceInterval = 10 * time.Millisecond
+
+// laneLayout retorna la Y superior del trípode del carril i de n y la escala
+// con que se dibuja. Con un solo dispositivo se conserva la posición original.
+func laneLayout(i, n int) (float64, float64) {
+	if n <= 1 {
+		return singleY, 1
+	}
+	h := (laneBottom - laneTop) / float64(n)
+	scale := math.Min(1, h/laneSprite)
+	return laneTop + float64(i)*h + (h-laneSprite*scale)/2, scale
+}
+
+// sway calcula la inclinación de un trípode automático en el instante t.
+type sway struct {
+	baseRoll, basePitch float64
+	amplitude, period   float64
+	phase               float64
+}
+
+func (s sway) at(t time.Duration) (float64, float64) {
+	if s.period <= 0 {
+		return s.baseRoll, s.basePitch
+	}
+	w := 2*math.Pi*t.Seconds()/s.period + s.phase
+	return s.baseRoll + s.amplitude*math.Sin(w), s.basePitch + s.amplitude*0.5*math.Cos(w)
+}
+
+// buildFleet crea los dispositivos de la flota repartidos entre projects.
+func buildFleet(projects []ProjectConfig, fleet FleetConfig, seed int64, interval time.Duration) []*Device {
+	n := fleet.Size
+	if n < len(projects) {
+		n = len(projects)
+	}
+
+	perProject := make(map[int]int)
+	for i := 0; i < n; i++ {
+		perProject[projects[i%len(projects)].ID]++
+	}
+
+	devices := make([]*Device, 0, n)
+	index := make(map[int]int)
+	for i := 0; i < n; i++ {
+		p := projects[i%len(projects)]
+		index[p.ID]++
+
+		d := NewDevice(p, seed)
+		d.Lane = i
+		d.Manual = i == 0
+		if perProject[p.ID] > 1 {
+			d.ID = fmt.Sprintf("%s-%02d", p.DeviceID, index[p.ID])
+			d.namespace = fmt.Sprintf("p%d-%d", p.ID, index[p.ID])
+		}
+
+		rng := d.Rand("fleet")
+		jitter := fleet.IntervalJitter * (2*rng.Float64() - 1)
+		d.Interval = interval
+		// El trípode manual (carril 0) conserva el intervalo configurado.
+		if i > 0 {
+			d.Interval = time.Duration(float64(interval) * (1 + jitter))
+			d.Offset = time.Duration(float64(interval) * fleet.IntervalJitter * rng.Float64())
+		}
+		if d.Interval < minDeviceInterval {
+			d.Interval = minDeviceInterval
+		}
+		d.sway = sway{
+			baseRoll:  (rng.Float64()*2 - 1) * 6,
+			basePitch: (rng.Float64()*2 - 1) * 3,
+			amplitude: fleet.SwayDeg,
+			period:    fleet.SwayPeriodS * (0.7 + 0.6*rng.Float64()),
+			phase:     rng.Float64() * 2 * math.Pi,
+		}
+		d.LaneY, d.Scale = laneLayout(i, n)
+
+		devices = append(devices, d)
+	}
+	return devices
+}
@@ -0,0 +1,111 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// IMXConfig describe la cámara IMX477 y la iluminación de la escena.
+type IMXConfig struct {
+	Resolution    string  `json:"resolution"`
+	AmbientLum    float64 `json:"ambient_lum"`     // luminosidad promedio de la escena
+	AmbientLumStd float64 `json:"ambient_lum_std"` // variación entre capturas
+	IdealLum      float64 `json:"ideal_lum"`       // exposición que da la mejor calidad
+	// El punto láser se detecta con 50% de probabilidad a LaserRangeM y
+	// cuando la luz ambiente llega a LaserWashoutLum.
+	LaserRangeM     float64 `json:"laser_range_m"`
+	LaserWashoutLum float64 `json:"laser_washout_lum"`
+	BaseSharpness   float64 `json:"base_sharpness"` // nitidez con la cámara quieta
+	// MotionBlur es cuánto cae la nitidez (en proporción) por cada °/s.
+	MotionBlur     float64 `json:"motion_blur"`
+	SharpnessNoise float64 `json:"sharpness_noise"`
+}
+
+// DefaultIMXConfig reproduce los rangos que usaba la versión aleatoria.
+func DefaultIMXConfig() IMXConfig {
+	return IMXConfig{
+		Resolution:      "640x480",
+		AmbientLum:      10,
+		AmbientLumStd:   3,
+		IdealLum:        12,
+		LaserRangeM:     5,
+		LaserWashoutLum: 22,
+		BaseSharpness:   5.8,
+		MotionBlur:      0.15,
+		SharpnessNoise:  0.15,
+	}
+}
+
+// Validate rechaza los valores con los que Sample no puede calcular la
+// calidad (p. ej. base_sharpness 0 da NaN).
+func (c IMXConfig) Validate() error {
+	if c.BaseSharpness <= 0 {
+		return fmt.Errorf("imx477: base_sharpness debe ser positivo (es %g)", c.BaseSharpness)
+	}
+	if c.IdealLum < 0 || c.AmbientLumStd < 0 || c.MotionBlur < 0 || c.SharpnessNoise < 0 {
+		return fmt.Errorf("imx477: ideal_lum, ambient_lum_std, motion_blur y sharpness_noise no pueden ser negativos")
+	}
+	return nil
+}
+
+// CameraScene es lo que "ve" la cámara en una captura: la distancia real al
+// objetivo (TF-Luna) y la velocidad angular del trípode (MPU6050).
+type CameraScene struct {
+	DistanceM   float64
+	RateDegPerS float64
+}
+
+// IMXModel genera capturas cuyas métricas dependen de la escena.
+type IMXModel struct {
+	cfg IMXConfig
+}
+
+// NewIMXModel crea el modelo de la cámara.
+func NewIMXModel(cfg IMXConfig) *IMXModel {
+	return &IMXModel{cfg: cfg}
+}
+
+// Sample produce la captura en now para la escena dada.
+func (m *IMXModel) Sample(rng *rand.Rand, now time.Time, scene CameraScene) IMXData {
+	cfg := m.cfg
+
+	lum := math.Max(cfg.AmbientLum+rng.NormFloat64()*cfg.AmbientLumStd, 0)
+
+	// El movimiento difumina la imagen: la nitidez decae con la velocidad
+	// angular y no vuelve a su base hasta que el trípode se estabiliza.
+	sharpness := cfg.BaseSharpness * math.Exp(-cfg.MotionBlur*scene.RateDegPerS)
+	sharpness = math.Max(sharpness+rng.NormFloat64()*cfg.SharpnessNoise, 0)
+
+	pDist := logistic((cfg.LaserRangeM - scene.DistanceM) / 0.6)
+	pLum := logistic((cfg.LaserWashoutLum - lum) / 2.5)
+	pBlur := math.Min(sharpness/cfg.BaseSharpness, 1)
+	laser := rng.Float64() < pDist*pLum*pBlur
+
+	exposure := 1.0
+	if cfg.IdealLum > 0 {
+		exposure = clamp(1-math.Abs(lum-cfg.IdealLum)/cfg.IdealLum, 0, 1)
+	}
+	quality := 100 * clamp(sharpness/cfg.BaseSharpness, 0, 1) * exposure
+
+	confidence := 0.2 + 0.4*quality/100
+	if laser {
+		confidence += 0.4 * pDist
+	}
+
+	return IMXData{
+		Resolution:     cfg.Resolution,
+		Luminosidad:    lum,
+		Nitidez:        sharpness,
+		LaserDetectado: laser,
+		CalidadFrame:   math.Round(quality*10) / 10,
+		Confiabilidad:  clamp(confidence+rng.NormFloat64()*0.02, 0, 1),
+		Event:          true,
+		Timestamp:      now.Format(TimestampLayout),
+	}
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
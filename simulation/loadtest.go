@@ -0,0 +1,197 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"geova-simulation/metrics"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// LoadConfig activa el modo de carga: en vez de que cada trípode envíe un
+// batch por intervalo, la flota completa envía a un ritmo objetivo.
+type LoadConfig struct {
+	// Rate es el objetivo en peticiones por segundo a cada sensor (0 = modo
+	// normal por intervalos). Cada batch suma una petición por sensor.
+	Rate float64 `json:"rate"`
+	// Concurrency limita los paquetes en curso a la vez (0 = sin límite).
+	// Los que exceden el límite esperan antes de registrarse.
+	Concurrency int `json:"concurrency"`
+	// RampUpMs sube el ritmo linealmente desde cero hasta Rate.
+	RampUpMs int `json:"ramp_up_ms"`
+	// SummaryPath es el archivo JSON donde se escribe el resumen al terminar
+	// la corrida ("" = no se escribe). Aplica también fuera del modo de carga.
+	SummaryPath string `json:"summary_path"`
+}
+
+// Enabled indica si hay un ritmo objetivo.
+func (c LoadConfig) Enabled() bool {
+	return c.Rate > 0
+}
+
+// RateAt es el ritmo objetivo tras elapsed desde el arranque.
+func (c LoadConfig) RateAt(elapsed time.Duration) float64 {
+	ramp := time.Duration(c.RampUpMs) * time.Millisecond
+	if ramp <= 0 || elapsed >= ramp {
+		return c.Rate
+	}
+	return c.Rate * float64(elapsed) / float64(ramp)
+}
+
+// loadTick es la resolución del generador de carga (en tiempo simulado);
+// los batches que caen dentro de un mismo tick se lanzan juntos.
+const loadTick = 10 * time.Millisecond
+
+// runLoad reparte batches entre los dispositivos, en ronda, al ritmo de
+// r.Load. Las fracciones de batch se acumulan de un tick al siguiente.
+func (r *Runner) runLoad(ctx, genCtx context.Context, devices []*Device, sent *int64, stopGen context.CancelFunc) {
+	fmt.Printf("[CARGA] Objetivo %.1f req/s por sensor, concurrencia máx %d, ramp-up %v\n",
+		r.Load.Rate, r.Load.Concurrency, time.Duration(r.Load.RampUpMs)*time.Millisecond)
+
+	start := r.Clock.Now()
+	last := start
+	var due float64
+	next := 0
+
+	for {
+		if r.Clock.Sleep(genCtx, loadTick) != nil {
+			return
+		}
+		now := r.Clock.Now()
+		rate := r.Load.RateAt(now - start)
+		due += rate * (now - last).Seconds()
+		last = now
+
+		r.State.Mutex.Lock()
+		r.State.Load.TargetRate = rate
+		r.State.Mutex.Unlock()
+
+		for ; due >= 1; due-- {
+			if len(devices) == 0 {
+				return
+			}
+			i := next % len(devices)
+			next++
+			n, err := r.sendDeviceBatch(ctx, devices[i])
+			if err != nil {
+				// Captura agotada: el resto de la flota sigue.
+				devices = append(devices[:i:i], devices[i+1:]...)
+				continue
+			}
+			if r.MaxPackets > 0 && int(atomic.AddInt64(sent, int64(n))) >= r.MaxPackets {
+				stopGen()
+				return
+			}
+		}
+	}
+}
+
+// acquire espera un lugar libre del límite de concurrencia. Retorna false
+// si ctx se cancela antes.
+func (r *Runner) acquire(ctx context.Context, slots chan struct{}) bool {
+	r.State.Mutex.Lock()
+	r.State.Load.Waiting++
+	r.State.Mutex.Unlock()
+
+	ok := false
+	select {
+	case slots <- struct{}{}:
+		ok = true
+	case <-ctx.Done():
+	}
+
+	r.State.Mutex.Lock()
+	r.State.Load.Waiting--
+	if ok {
+		r.State.Load.InFlight++
+	}
+	r.State.Mutex.Unlock()
+	return ok
+}
+
+func (r *Runner) release(slots chan struct{}) {
+	<-slots
+	r.State.Mutex.Lock()
+	if r.State.Load.InFlight > 0 {
+		r.State.Load.InFlight--
+	}
+	r.State.Mutex.Unlock()
+}
+
+// RunSummary es el contenido del archivo de resumen.
+type RunSummary struct {
+	StartedAt   time.Time `json:"started_at"`
+	DurationS   float64   `json:"duration_s"`
+	Seed        int64     `json:"seed"`
+	Devices     int       `json:"devices"`
+	TargetRate  float64   `json:"target_rate"`
+	Concurrency int       `json:"concurrency"`
+	RampUpMs    int       `json:"ramp_up_ms"`
+
+	Created   int `json:"created"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Retries   int `json:"retries"`
+	Cancelled int `json:"cancelled"`
+	Delivered int `json:"delivered"`
+	Lost      int `json:"lost"`
+
+	// DurationS y AchievedRate se miden en tiempo de la simulación, como
+	// TargetRate. AchievedRate son las respuestas por segundo a cada sensor.
+	AchievedRate float64 `json:"achieved_rate"`
+	ErrorRate    float64 `json:"error_rate"`
+
+	Endpoints []metrics.Summary `json:"endpoints"`
+}
+
+// Summary arma el resumen de la última corrida de Run.
+func (r *Runner) Summary() RunSummary {
+	r.State.Mutex.Lock()
+	stats := r.State.Stats
+	devices := len(r.State.Devices)
+	r.State.Mutex.Unlock()
+
+	// El ritmo objetivo es por segundo de Clock: a 2× se mide igual.
+	elapsed := r.Clock.Now() - r.startedAt
+	s := RunSummary{
+		StartedAt:   r.started,
+		DurationS:   elapsed.Seconds(),
+		Seed:        r.Seed,
+		Devices:     devices,
+		TargetRate:  r.Load.Rate,
+		Concurrency: r.Load.Concurrency,
+		RampUpMs:    r.Load.RampUpMs,
+		Created:     stats.Created,
+		Succeeded:   stats.Succeeded,
+		Failed:      stats.Failed,
+		Retries:     stats.Retries,
+		Cancelled:   stats.Cancelled,
+		Delivered:   stats.Delivered,
+		Lost:        stats.Lost,
+		ErrorRate:   stats.ErrorRate(),
+		Endpoints:   r.State.Latency.Summaries(),
+	}
+	if elapsed > 0 {
+		s.AchievedRate = float64(stats.Succeeded+stats.Failed) / float64(len(SensorKinds)) / elapsed.Seconds()
+	}
+	return s
+}
+
+// WriteSummary escribe Summary en Load.SummaryPath (no hace nada si está
+// vacío o si Run nunca arrancó).
+func (r *Runner) WriteSummary() error {
+	if r.Load.SummaryPath == "" || r.started.IsZero() {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Summary(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.Load.SummaryPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("no se pudo escribir el resumen '%s': %w", r.Load.SummaryPath, err)
+	}
+	fmt.Printf("[CARGA] Resumen escrito en %s\n", r.Load.SummaryPath)
+	return nil
+}
@@ -0,0 +1,117 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Gravity es la aceleración de la gravedad en m/s².
+const Gravity = 9.80665
+
+// MPUConfig define las imperfecciones del MPU6050 simulado. La aceleración
+// está en m/s² y el giroscopio en °/s.
+type MPUConfig struct {
+	AccelNoiseStd float64    `json:"accel_noise_std"`
+	GyroNoiseStd  float64    `json:"gyro_noise_std"`
+	AccelBias     [3]float64 `json:"accel_bias"`
+	GyroBias      [3]float64 `json:"gyro_bias"`
+	// GyroBiasDrift es la desviación del random walk del bias en °/s por √s.
+	GyroBiasDrift float64 `json:"gyro_bias_drift"`
+}
+
+// DefaultMPUConfig usa valores típicos de un MPU6050 sin calibrar.
+func DefaultMPUConfig() MPUConfig {
+	return MPUConfig{
+		AccelNoiseStd: 0.03,
+		GyroNoiseStd:  0.05,
+		AccelBias:     [3]float64{0.04, -0.03, 0.06},
+		GyroBias:      [3]float64{0.4, -0.25, 0.15},
+		GyroBiasDrift: 0.01,
+	}
+}
+
+// Validate rechaza desviaciones negativas, con las que NormFloat64 invierte
+// el ruido en lugar de anularlo.
+func (c MPUConfig) Validate() error {
+	if c.AccelNoiseStd < 0 || c.GyroNoiseStd < 0 || c.GyroBiasDrift < 0 {
+		return fmt.Errorf("mpu6050: accel_noise_std, gyro_noise_std y gyro_bias_drift no pueden ser negativos")
+	}
+	return nil
+}
+
+// MPUModel genera lecturas físicamente consistentes a partir del roll y
+// pitch reales del trípode: la gravedad se proyecta sobre los ejes del
+// acelerómetro y el giroscopio mide la velocidad de cambio entre muestras.
+// Roll y Pitch del payload son la verdad de referencia, para que el backend
+// pueda comparar su propia estimación de ángulos.
+type MPUModel struct {
+	cfg      MPUConfig
+	gyroBias [3]float64
+
+	started   bool
+	lastRoll  float64
+	lastPitch float64
+	lastTime  time.Time
+
+	// LastRateDegPerS es la velocidad angular real de la última muestra.
+	LastRateDegPerS float64
+}
+
+// NewMPUModel crea el modelo con el bias inicial de cfg.
+func NewMPUModel(cfg MPUConfig) *MPUModel {
+	return &MPUModel{cfg: cfg, gyroBias: cfg.GyroBias}
+}
+
+// Sample produce la lectura en now para el roll y pitch indicados (grados).
+func (m *MPUModel) Sample(rng *rand.Rand, now time.Time, roll, pitch float64) MPUData {
+	var rollRate, pitchRate float64
+	if m.started {
+		dt := now.Sub(m.lastTime).Seconds()
+		if dt > 0 {
+			rollRate = (roll - m.lastRoll) / dt
+			pitchRate = (pitch - m.lastPitch) / dt
+
+			drift := m.cfg.GyroBiasDrift * math.Sqrt(dt)
+			for i := range m.gyroBias {
+				m.gyroBias[i] += rng.NormFloat64() * drift
+			}
+		}
+	}
+	m.LastRateDegPerS = math.Hypot(rollRate, pitchRate)
+	m.started = true
+	m.lastRoll, m.lastPitch, m.lastTime = roll, pitch, now
+
+	phi := roll * math.Pi / 180
+	theta := pitch * math.Pi / 180
+
+	accel := [3]float64{
+		-Gravity * math.Sin(theta),
+		Gravity * math.Sin(phi) * math.Cos(theta),
+		Gravity * math.Cos(phi) * math.Cos(theta),
+	}
+	gyro := [3]float64{rollRate, pitchRate, 0}
+
+	for i := range accel {
+		accel[i] += m.cfg.AccelBias[i] + rng.NormFloat64()*m.cfg.AccelNoiseStd
+		gyro[i] += m.gyroBias[i] + rng.NormFloat64()*m.cfg.GyroNoiseStd
+	}
+
+	// Apertura: ángulo entre el eje vertical del trípode y la gravedad.
+	apertura := math.Acos(math.Cos(phi)*math.Cos(theta)) * 180 / math.Pi
+
+	return MPUData{
+		Ax:        accel[0],
+		Ay:        accel[1],
+		Az:        accel[2],
+		Gx:        gyro[0],
+		Gy:        gyro[1],
+		Gz:        gyro[2],
+		Roll:      roll,
+		Pitch:     pitch,
+		Apertura:  apertura,
+		Event:     true,
+		Timestamp: now.Format(TimestampLayout),
+	}
+}
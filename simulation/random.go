@@ -0,0 +1,29 @@
+package simulation
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// SeededEpoch es el instante desde el que se cuentan los timestamps cuando la
+// corrida tiene semilla fija, para que los payloads no dependan del reloj.
+var SeededEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DeriveRand crea un generador independiente para stream a partir de seed.
+// Cada sensor y cada paquete usan su propio stream, de modo que el orden en
+// que el scheduler ejecuta las goroutines no altera los valores generados.
+func DeriveRand(seed int64, stream string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return rand.New(rand.NewSource(int64(splitmix64(uint64(seed) ^ h.Sum64()))))
+}
+
+// splitmix64 dispersa los bits para que semillas cercanas no den streams
+// parecidos.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
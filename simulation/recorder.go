@@ -0,0 +1,156 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"geova-simulation/state"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tipos de línea del archivo de sesión.
+const (
+	EventSession    = "session"
+	EventPacket     = "packet"
+	EventAttempt    = "attempt"
+	EventTransition = "transition"
+)
+
+// SessionEvent es una línea del archivo de sesión (JSONL). Cada paquete deja
+// una línea "packet" al crearse, una "attempt" por petición HTTP y una
+// "transition" por cada cambio de estado de la FSM.
+type SessionEvent struct {
+	Type      string    `json:"type"`
+	At        time.Time `json:"at"`
+	ID        string    `json:"id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	// session
+	Seed int64 `json:"seed,omitempty"`
+
+	// packet
+	Batch     int             `json:"batch,omitempty"`
+	Sensor    string          `json:"sensor,omitempty"`
+	ProjectID int             `json:"project_id,omitempty"`
+	DeviceID  string          `json:"device_id,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	RetryOf   string          `json:"retry_of,omitempty"` // reenvío desde dead letters
+
+	// attempt
+	SentAt     time.Time `json:"sent_at,omitempty"` // cuándo salió la petición
+	Attempt    int       `json:"attempt,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMs  float64   `json:"latency_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+
+	// transition
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	Reason string `json:"reason,omitempty"` // al pasar a Error
+}
+
+// Recorder escribe la sesión en un archivo que sólo crece: cada corrida
+// agrega una línea "session" seguida de sus eventos. Implementa
+// state.Observer.
+type Recorder struct {
+	mutex  sync.Mutex
+	file   *os.File
+	enc    *json.Encoder
+	path   string
+	failed bool
+}
+
+// NewRecorder abre (o crea) path en modo append.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la sesión '%s': %w", path, err)
+	}
+	return &Recorder{file: f, enc: json.NewEncoder(f), path: path}, nil
+}
+
+// StartSession marca el comienzo de una corrida.
+func (r *Recorder) StartSession(seed int64) {
+	r.write(SessionEvent{Type: EventSession, At: time.Now(), Seed: seed})
+}
+
+// PacketCreated graba el payload tal como lo serializa SendPOSTRequest.
+func (r *Recorder) PacketCreated(p *state.PacketState) {
+	payload, err := json.Marshal(p.Payload)
+	if err != nil {
+		fmt.Printf("[SESIÓN] No se pudo serializar %s: %v\n", p.ID, err)
+		return
+	}
+	r.write(SessionEvent{
+		Type:      EventPacket,
+		At:        time.Now(),
+		ID:        p.ID,
+		RequestID: p.CorrelationID,
+		Batch:     p.Batch,
+		Sensor:    p.Sensor,
+		ProjectID: p.ProjectID,
+		DeviceID:  p.DeviceID,
+		Payload:   payload,
+		RetryOf:   p.RetryOf,
+	})
+}
+
+// AttemptDone graba el resultado de una petición HTTP.
+func (r *Recorder) AttemptDone(p *state.PacketState, attempt, statusCode int, latency time.Duration, err error) {
+	now := time.Now()
+	e := SessionEvent{
+		Type:       EventAttempt,
+		At:         now,
+		SentAt:     now.Add(-latency),
+		ID:         p.ID,
+		RequestID:  p.CorrelationID,
+		Attempt:    attempt,
+		StatusCode: statusCode,
+		LatencyMs:  float64(latency) / float64(time.Millisecond),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.write(e)
+}
+
+// Transition graba un cambio de estado de la FSM.
+func (r *Recorder) Transition(p *state.PacketState, from, to state.PacketStatus) {
+	e := SessionEvent{
+		Type:  EventTransition,
+		At:    time.Now(),
+		ID:    p.ID,
+		From:  from.String(),
+		To:    to.String(),
+		Stage: p.Stage,
+	}
+	if to == state.Error {
+		e.Reason = p.FailureReason
+	}
+	r.write(e)
+}
+
+// Phase no se graba: el replay sólo necesita paquetes e intentos.
+func (r *Recorder) Phase(*state.PacketState, string, time.Time, time.Time) {}
+
+// Close cierra el archivo. Es seguro sobre nil.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+func (r *Recorder) write(e SessionEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.enc.Encode(e); err != nil && !r.failed {
+		// Se avisa una sola vez para no inundar la consola.
+		r.failed = true
+		fmt.Printf("[SESIÓN] Error al escribir '%s': %v\n", r.path, err)
+	}
+}
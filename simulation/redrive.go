@@ -0,0 +1,92 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"geova-simulation/state"
+)
+
+// Redrive reenvía el Payload original de un paquete en dead letters como un
+// paquete nuevo ("<id>-r", con RetryOf apuntando al original) que sale
+// desde donde está el original. El original deja la zona de dead letters y
+// se oculta, pero sigue en State.Packets para el inspector.
+func (r *Runner) Redrive(ctx context.Context, id string) (*state.PacketState, error) {
+	if ctx == nil || ctx.Err() != nil {
+		return nil, fmt.Errorf("la simulación está detenida")
+	}
+
+	r.State.Mutex.Lock()
+	orig, ok := r.State.Packets[id]
+	if !ok || !removeID(&r.State.DeadLetters, id) {
+		r.State.Mutex.Unlock()
+		return nil, fmt.Errorf("%s no está en dead letters", id)
+	}
+	d := r.deviceFor(orig.DeviceID)
+	orig.RedrivenAs = orig.ID + "-r"
+	orig.Active = false
+	r.State.Stats.Redriven++
+
+	entry := r.Topology.Entry()
+	packet := &state.PacketState{
+		ID:            orig.RedrivenAs,
+		Batch:         orig.Batch,
+		CorrelationID: orig.CorrelationID + "-r",
+		Sensor:        orig.Sensor,
+		ProjectID:     orig.ProjectID,
+		DeviceID:      orig.DeviceID,
+		Active:        true,
+		X:             orig.X,
+		Y:             orig.Y,
+		TargetX:       entry.X,
+		TargetY:       entry.Y,
+		Color:         orig.Color,
+		Status:        state.SendingToAPI,
+		Payload:       orig.Payload,
+		RetryOf:       orig.ID,
+	}
+	r.State.Mutex.Unlock()
+
+	fmt.Printf("[DLQ] [%s] Reenviando como %s\n", orig.LogTag(), packet.ID)
+	r.launch(ctx, d, packet)
+	return packet, nil
+}
+
+// RedriveAll reenvía todos los paquetes en dead letters y retorna cuántos.
+func (r *Runner) RedriveAll(ctx context.Context) int {
+	r.State.Mutex.Lock()
+	ids := append([]string(nil), r.State.DeadLetters...)
+	r.State.Mutex.Unlock()
+
+	n := 0
+	for _, id := range ids {
+		if _, err := r.Redrive(ctx, id); err != nil {
+			fmt.Printf("[DLQ] %v\n", err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// deviceFor busca el dispositivo del paquete en la flota (el primero si ya
+// no existe, como en un replay). Se llama con State.Mutex tomado, después
+// de una corrida, así que la flota no está vacía.
+func (r *Runner) deviceFor(id string) *Device {
+	for _, d := range r.devices {
+		if d.ID == id {
+			return d
+		}
+	}
+	return r.devices[0]
+}
+
+// removeID quita id de ids y retorna si estaba.
+func removeID(ids *[]string, id string) bool {
+	for i, other := range *ids {
+		if other == id {
+			*ids = append((*ids)[:i], (*ids)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package simulation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RecordedPacket es un paquete de una sesión grabada. Offset es el momento
+// en que salió su primera petición HTTP, relativo al primer envío de la
+// sesión (ya incluye la demora visual, que el replay no vuelve a sortear).
+type RecordedPacket struct {
+	Offset    time.Duration
+	ID        string
+	Batch     int
+	Sensor    SensorKind
+	ProjectID int
+	DeviceID  string
+	Payload   interface{}
+}
+
+// LoadSession lee los paquetes de la última sesión grabada en path.
+func LoadSession(path string) ([]RecordedPacket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la sesión '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	var packets []RecordedPacket
+	// sent es cuándo salió cada paquete (su primer intento o, si no llegó a
+	// enviarse, cuándo se creó), en el mismo orden que packets.
+	var sent []time.Time
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e SessionEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		switch e.Type {
+		case EventSession:
+			packets, sent, index = nil, nil, make(map[string]int)
+		case EventAttempt:
+			i, ok := index[e.ID]
+			if !ok || e.Attempt != 1 {
+				continue
+			}
+			sent[i] = e.SentAt
+			if sent[i].IsZero() {
+				// Sesiones grabadas antes de sent_at.
+				sent[i] = e.At.Add(-time.Duration(e.LatencyMs * float64(time.Millisecond)))
+			}
+		case EventPacket:
+			payload, err := decodePayload(SensorKind(e.Sensor), e.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			index[e.ID] = len(packets)
+			sent = append(sent, e.At)
+			packets = append(packets, RecordedPacket{
+				ID:        e.ID,
+				Batch:     e.Batch,
+				Sensor:    SensorKind(e.Sensor),
+				ProjectID: e.ProjectID,
+				DeviceID:  e.DeviceID,
+				Payload:   payload,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("no se pudo leer la sesión '%s': %w", path, err)
+	}
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("la sesión '%s' no tiene paquetes", path)
+	}
+
+	first := sent[0]
+	for _, t := range sent {
+		if t.Before(first) {
+			first = t
+		}
+	}
+	for i := range packets {
+		packets[i].Offset = sent[i].Sub(first)
+	}
+	// Con la demora de cada worker, el orden de envío no es el de creación.
+	sort.SliceStable(packets, func(i, j int) bool { return packets[i].Offset < packets[j].Offset })
+	return packets, nil
+}
+
+// decodePayload recupera el tipo concreto para que el dashboard lo reconozca.
+func decodePayload(kind SensorKind, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case SensorTFLuna:
+		var d TFLunaData
+		err := json.Unmarshal(raw, &d)
+		return d, err
+	case SensorMPU:
+		var d MPUData
+		err := json.Unmarshal(raw, &d)
+		return d, err
+	case SensorIMX:
+		var d IMXData
+		err := json.Unmarshal(raw, &d)
+		return d, err
+	}
+	return nil, fmt.Errorf("sensor desconocido '%s'", kind)
+}
+
+// runReplay reenvía r.Replay respetando los tiempos originales divididos
+// por ReplaySpeed, sin la demora visual de los workers (ver launch). Cada
+// paquete sale del carril de su dispositivo si sigue existiendo en la
+// flota, o del primero si no.
+func (r *Runner) runReplay(ctx, genCtx context.Context, devices []*Device, sent *int64, stopGen context.CancelFunc) {
+	speed := r.ReplaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+	byID := make(map[string]*Device, len(devices))
+	for _, d := range devices {
+		byID[d.ID] = d
+	}
+	fmt.Printf("[REPLAY] Reenviando %d paquetes a %gx\n", len(r.Replay), speed)
+
+	start := r.Clock.Now()
+	for _, rec := range r.Replay {
+		wait := time.Duration(float64(rec.Offset)/speed) - (r.Clock.Now() - start)
+		if wait > 0 && r.Clock.Sleep(genCtx, wait) != nil {
+			return
+		}
+		if genCtx.Err() != nil {
+			return
+		}
+
+		d, ok := byID[rec.DeviceID]
+		if !ok {
+			d = devices[0]
+		}
+		packet := r.newPacket(d, rec.Sensor, rec.ID, rec.Batch, rec.Payload)
+		packet.ProjectID = rec.ProjectID
+		packet.DeviceID = rec.DeviceID
+		r.launch(ctx, d, packet)
+
+		if r.MaxPackets > 0 && int(atomic.AddInt64(sent, 1)) >= r.MaxPackets {
+			stopGen()
+			return
+		}
+	}
+	fmt.Println("[REPLAY] Sesión completa")
+}
@@ -0,0 +1,131 @@
+package simulation
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Clases de error de red que se pueden reintentar.
+const (
+	NetErrTimeout           = "timeout"
+	NetErrConnectionRefused = "connection_refused"
+	NetErrConnectionReset   = "connection_reset"
+	NetErrDNS               = "dns"
+	NetErrEOF               = "eof"
+)
+
+// RetryPolicy define cuántas veces y con qué espera se reintenta un envío.
+// La espera del intento n es BaseDelayMs*Multiplier^(n-1), limitada a
+// MaxDelayMs, y se le resta hasta un Jitter (0..1) proporcional al azar.
+// Jitter es un puntero para distinguir "jitter": 0 de un campo ausente.
+type RetryPolicy struct {
+	MaxAttempts        int      `json:"max_attempts"`
+	BaseDelayMs        float64  `json:"base_delay_ms"`
+	MaxDelayMs         float64  `json:"max_delay_ms"`
+	Multiplier         float64  `json:"multiplier"`
+	Jitter             *float64 `json:"jitter"`
+	RetryOnStatus      []int    `json:"retry_on_status"`
+	RetryNetworkErrors []string `json:"retry_network_errors"`
+}
+
+// DefaultRetryPolicy reintenta hasta 3 veces ante saturación o caídas.
+func DefaultRetryPolicy() RetryPolicy {
+	jitter := 0.5
+	return RetryPolicy{
+		MaxAttempts:   3,
+		BaseDelayMs:   400,
+		MaxDelayMs:    5000,
+		Multiplier:    2,
+		Jitter:        &jitter,
+		RetryOnStatus: []int{429, 502, 503, 504},
+		RetryNetworkErrors: []string{
+			NetErrTimeout, NetErrConnectionRefused, NetErrConnectionReset, NetErrEOF,
+		},
+	}
+}
+
+func (p RetryPolicy) merge(o RetryPolicy) RetryPolicy {
+	if o.MaxAttempts != 0 {
+		p.MaxAttempts = o.MaxAttempts
+	}
+	if o.BaseDelayMs != 0 {
+		p.BaseDelayMs = o.BaseDelayMs
+	}
+	if o.MaxDelayMs != 0 {
+		p.MaxDelayMs = o.MaxDelayMs
+	}
+	if o.Multiplier != 0 {
+		p.Multiplier = o.Multiplier
+	}
+	if o.Jitter != nil {
+		p.Jitter = o.Jitter
+	}
+	if o.RetryOnStatus != nil {
+		p.RetryOnStatus = o.RetryOnStatus
+	}
+	if o.RetryNetworkErrors != nil {
+		p.RetryNetworkErrors = o.RetryNetworkErrors
+	}
+	return p
+}
+
+// Backoff calcula la espera antes del intento attempt+1.
+func (p RetryPolicy) Backoff(attempt int, rng *rand.Rand) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	ms := p.BaseDelayMs * math.Pow(mult, float64(attempt-1))
+	if p.MaxDelayMs > 0 && ms > p.MaxDelayMs {
+		ms = p.MaxDelayMs
+	}
+	if p.Jitter != nil && *p.Jitter > 0 {
+		ms -= ms * math.Min(*p.Jitter, 1) * rng.Float64()
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// ShouldRetry indica si el resultado de un intento amerita reintentar.
+func (p RetryPolicy) ShouldRetry(statusCode int, err error) bool {
+	if err != nil {
+		class := ClassifyNetError(err)
+		for _, c := range p.RetryNetworkErrors {
+			if c == class {
+				return true
+			}
+		}
+		return false
+	}
+	for _, code := range p.RetryOnStatus {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyNetError agrupa un error de red en una de las clases NetErr*.
+// Retorna "" si no corresponde a ninguna.
+func ClassifyNetError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return NetErrDNS
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return NetErrTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return NetErrConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return NetErrConnectionReset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NetErrEOF
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	none := 0.0
+	p := RetryPolicy{BaseDelayMs: 100, MaxDelayMs: 1000, Multiplier: 2, Jitter: &none}
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"primer intento", p, 1, 100 * time.Millisecond},
+		{"exponencial", p, 3, 400 * time.Millisecond},
+		{"limitado a max_delay_ms", p, 6, time.Second},
+		{"sin límite", RetryPolicy{BaseDelayMs: 100, Multiplier: 2}, 6, 3200 * time.Millisecond},
+		{"multiplicador menor que 1", RetryPolicy{BaseDelayMs: 100, Multiplier: 0.5}, 4, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt, rand.New(rand.NewSource(1))); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, se esperaba %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := DefaultRetryPolicy()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		// Jitter 0.5 resta hasta la mitad de los 400ms del primer intento.
+		if got := p.Backoff(1, rng); got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Fatalf("Backoff con jitter = %v, fuera de [200ms, 400ms]", got)
+		}
+	}
+
+	// "jitter": 0 en el JSON anula el jitter por defecto.
+	var o RetryPolicy
+	if err := json.Unmarshal([]byte(`{"jitter": 0}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	p = p.merge(o)
+	for i := 0; i < 10; i++ {
+		if got := p.Backoff(1, rng); got != 400*time.Millisecond {
+			t.Fatalf("Backoff con jitter 0 = %v, se esperaba 400ms", got)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := DefaultRetryPolicy()
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"503", 503, nil, true},
+		{"429", 429, nil, true},
+		{"500", 500, nil, false},
+		{"200", 200, nil, false},
+		{"timeout", 0, os.ErrDeadlineExceeded, true},
+		{"dns no está en la política", 0, &net.DNSError{Name: "geova.local"}, false},
+		{"error desconocido", 0, errors.New("x"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.ShouldRetry(tt.status, tt.err); got != tt.want {
+				t.Errorf("ShouldRetry(%d, %v) = %v, se esperaba %v", tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyNetError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"dns", &net.DNSError{Name: "geova.local", IsNotFound: true}, NetErrDNS},
+		{"deadline", fmt.Errorf("post: %w", os.ErrDeadlineExceeded), NetErrTimeout},
+		{"timeout de red", &net.DNSError{IsTimeout: true}, NetErrDNS},
+		{"conexión rechazada", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, NetErrConnectionRefused},
+		{"conexión reiniciada", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, NetErrConnectionReset},
+		{"eof", fmt.Errorf("post: %w", io.EOF), NetErrEOF},
+		{"eof inesperado", io.ErrUnexpectedEOF, NetErrEOF},
+		{"otro", errors.New("x"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyNetError(tt.err); got != tt.want {
+				t.Errorf("ClassifyNetError(%v) = %q, se esperaba %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
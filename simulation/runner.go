@@ -0,0 +1,318 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"geova-simulation/state"
+	"image/color"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runner genera los batches de peticiones mientras la simulación está activa.
+// Lo usan tanto la ventana (game) como el modo headless.
+type Runner struct {
+	State     *state.VisualState
+	Client    *http.Client
+	Endpoints EndpointRegistry
+
+	// Interval es la separación entre batches (2s si es cero).
+	Interval time.Duration
+	// MaxPackets detiene la generación tras crear esa cantidad de paquetes
+	// (0 = sin límite). Los paquetes ya lanzados siguen su curso.
+	MaxPackets int
+	// Duration detiene la generación tras ese tiempo de Clock (0 = sin
+	// límite).
+	Duration time.Duration
+
+	// Seed alimenta un stream aleatorio por proyecto, sensor y paquete. Con
+	// la misma semilla se generan los mismos payloads en cada corrida.
+	Seed int64
+	// FixedClock toma los timestamps de SeededEpoch + batch*Interval en vez
+	// del reloj; con la misma Seed los payloads son idénticos byte a byte.
+	FixedClock bool
+
+	// Projects son los proyectos simulados en paralelo (DefaultProject si
+	// está vacío).
+	Projects []ProjectConfig
+	// Fleet define cuántos trípodes se simulan a la vez.
+	Fleet FleetConfig
+	// Load reemplaza los intervalos por un ritmo objetivo (si Rate > 0).
+	Load LoadConfig
+
+	// Replay, si no está vacío, reemplaza la generación por los paquetes de
+	// una sesión grabada; ReplaySpeed escala sus tiempos (2 = el doble de
+	// rápido, 0 = 1).
+	Replay      []RecordedPacket
+	ReplaySpeed float64
+	// Recorder marca en la sesión grabada el inicio de cada corrida.
+	Recorder *Recorder
+	// Tracer escribe al empezar cada corrida las trazas que dejó abiertas la
+	// anterior.
+	Tracer *Tracer
+	// Topology indica hacia dónde viajan los paquetes desde el trípode (su
+	// etapa de tipo api).
+	Topology *Topology
+	// Clock marca el ritmo de los batches y de las esperas de los workers
+	// (nil = reloj de pared).
+	Clock *Clock
+
+	started time.Time
+	// startedAt es Clock.Now() al arrancar Run; el resumen mide desde ahí.
+	startedAt time.Duration
+	slots     chan struct{}
+	// workers cuenta los SendPOSTRequest en curso (ver Wait).
+	workers sync.WaitGroup
+	runs    int
+	run     string
+	// devices es la flota de la última corrida; se lee y escribe con
+	// State.Mutex tomado porque Redrive llega desde la ventana.
+	devices []*Device
+}
+
+// Run arranca un loop de envíos por dispositivo, cada uno con su propio
+// intervalo (o un único generador a ritmo fijo en modo de carga), y retorna
+// cuando todos terminan: al cancelarse ctx o al alcanzarse MaxPackets o
+// Duration. Los workers reciben ctx, así que cancelarlo también aborta las
+// peticiones en curso.
+func (r *Runner) Run(ctx context.Context) {
+	devices := r.PublishDevices()
+
+	r.started = time.Now()
+	r.startedAt = r.Clock.Now()
+	r.run = runToken(r.Seed, r.runs)
+	r.runs++
+	r.slots = nil
+	if r.Load.Concurrency > 0 {
+		r.slots = make(chan struct{}, r.Load.Concurrency)
+	}
+	r.State.Mutex.Lock()
+	r.State.Load = state.LoadInfo{Enabled: r.Load.Enabled()}
+	r.State.Mutex.Unlock()
+	if r.Recorder != nil {
+		r.Recorder.StartSession(r.Seed)
+	}
+	r.Tracer.Flush()
+
+	genCtx, stopGen := context.WithCancel(ctx)
+	defer stopGen()
+	if r.Duration > 0 {
+		go func() {
+			if r.Clock.Sleep(genCtx, r.Duration) == nil {
+				stopGen()
+			}
+		}()
+	}
+
+	var sent int64
+	var wg sync.WaitGroup
+	switch {
+	case len(r.Replay) > 0:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runReplay(ctx, genCtx, devices, &sent, stopGen)
+		}()
+	case r.Load.Enabled():
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runLoad(ctx, genCtx, devices, &sent, stopGen)
+		}()
+	default:
+		for _, d := range devices {
+			wg.Add(1)
+			go func(d *Device) {
+				defer wg.Done()
+				r.runDevice(ctx, genCtx, d, &sent, stopGen)
+			}(d)
+		}
+	}
+	wg.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+	case r.MaxPackets > 0 && int(atomic.LoadInt64(&sent)) >= r.MaxPackets:
+		fmt.Printf("[SIMULACIÓN] Límite de %d paquetes alcanzado\n", r.MaxPackets)
+	case r.Duration > 0:
+		fmt.Printf("[SIMULACIÓN] Duración de %v cumplida\n", r.Duration)
+	}
+}
+
+// Wait espera a que terminen los workers lanzados hasta ahora. Tras
+// cancelar el ctx de Run y esperar a que retorne, garantiza que ningún
+// paquete de esa corrida vuelva a tocar State.
+func (r *Runner) Wait() {
+	r.workers.Wait()
+}
+
+// runDevice es el calendario de envíos de un dispositivo. genCtx corta la
+// generación; ctx se pasa a los workers.
+func (r *Runner) runDevice(ctx, genCtx context.Context, d *Device, sent *int64, stopGen context.CancelFunc) {
+	if d.Offset > 0 {
+		if r.Clock.Sleep(genCtx, d.Offset) != nil {
+			return
+		}
+	}
+
+	for {
+		n, err := r.sendDeviceBatch(ctx, d)
+		if err != nil {
+			return
+		}
+		if r.MaxPackets > 0 && int(atomic.AddInt64(sent, int64(n))) >= r.MaxPackets {
+			stopGen()
+			return
+		}
+		if r.Clock.Sleep(genCtx, d.Interval) != nil {
+			return
+		}
+	}
+}
+
+func (r *Runner) interval() time.Duration {
+	if r.Interval <= 0 {
+		return 2 * time.Second
+	}
+	return r.Interval
+}
+
+// PublishDevices crea la flota desde cero (modelos y contadores nuevos) y
+// publica sus carriles en State para que la ventana los dibuje.
+func (r *Runner) PublishDevices() []*Device {
+	projects := r.Projects
+	if len(projects) == 0 {
+		projects = []ProjectConfig{DefaultProject()}
+	}
+	devices := buildFleet(projects, r.Fleet, r.Seed, r.interval())
+
+	infos := make([]state.DeviceInfo, len(devices))
+	for i, d := range devices {
+		infos[i] = state.DeviceInfo{
+			ID:        d.ID,
+			ProjectID: d.Project.ID,
+			Manual:    d.Manual,
+			LaneY:     d.LaneY,
+			Scale:     d.Scale,
+		}
+	}
+
+	r.State.Mutex.Lock()
+	r.State.Devices = infos
+	r.devices = devices
+	r.State.Mutex.Unlock()
+	return devices
+}
+
+// sendDeviceBatch genera un batch del dispositivo, lanza una goroutine por
+// sensor y retorna cuántas lanzó. Retorna error si el dispositivo ya no
+// tiene lecturas (captura agotada).
+func (r *Runner) sendDeviceBatch(ctx context.Context, d *Device) (int, error) {
+	now := time.Now()
+	if r.FixedClock {
+		now = d.seededNow()
+	}
+
+	r.State.Mutex.Lock()
+	roll, pitch := r.State.CurrentTilt, r.State.CurrentPitch
+	if !d.Manual {
+		roll, pitch = d.Tilt(now)
+		if d.Lane < len(r.State.Devices) {
+			r.State.Devices[d.Lane].Tilt = roll
+			r.State.Devices[d.Lane].Pitch = pitch
+		}
+	}
+	r.State.Mutex.Unlock()
+
+	genStart := time.Now()
+	batch, err := d.Sample(now, roll, pitch)
+	genEnd := time.Now()
+	if err != nil {
+		fmt.Printf("[CAPTURA] %s sin más lecturas: %v\n", d.ID, err)
+		return 0, err
+	}
+
+	r.State.Mutex.Lock()
+	r.State.PacketID++
+	if d.Captured(SensorMPU) && d.Lane < len(r.State.Devices) {
+		// El trípode se dibuja con la inclinación que registró la captura.
+		r.State.Devices[d.Lane].Tilt = batch.MPU.Roll
+		r.State.Devices[d.Lane].Pitch = batch.MPU.Pitch
+	}
+	r.State.Mutex.Unlock()
+
+	packets := []*state.PacketState{
+		r.newPacket(d, SensorTFLuna, d.PacketID(SensorTFLuna, batch.N), batch.N, batch.TFLuna),
+		r.newPacket(d, SensorMPU, d.PacketID(SensorMPU, batch.N), batch.N, batch.MPU),
+		r.newPacket(d, SensorIMX, d.PacketID(SensorIMX, batch.N), batch.N, batch.IMX),
+	}
+	for _, p := range packets {
+		p.GenStart, p.GenEnd = genStart, genEnd
+		r.launch(ctx, d, p)
+	}
+	return len(packets), nil
+}
+
+// sensorStyle es la separación vertical dentro del carril y el color de los
+// paquetes de cada sensor.
+func sensorStyle(kind SensorKind) (offsetY float64, c color.Color) {
+	switch kind {
+	case SensorTFLuna:
+		return -20, color.RGBA{R: 255, G: 50, B: 50, A: 255}
+	case SensorMPU:
+		return 0, color.RGBA{R: 50, G: 150, B: 255, A: 255}
+	default:
+		return 20, color.RGBA{R: 50, G: 255, B: 50, A: 255}
+	}
+}
+
+// newPacket crea el paquete a la altura del trípode del dispositivo.
+func (r *Runner) newPacket(d *Device, kind SensorKind, id string, batch int, payload interface{}) *state.PacketState {
+	offsetY, c := sensorStyle(kind)
+	entry := r.Topology.Entry()
+	return &state.PacketState{
+		ID:            id,
+		Batch:         batch,
+		CorrelationID: CorrelationID(r.run, id, batch),
+		Sensor:        string(kind),
+		ProjectID:     d.Project.ID,
+		DeviceID:      d.ID,
+		Active:        true,
+		X:             laneX,
+		Y:             d.LaneY + offsetY*d.Scale,
+		TargetX:       entry.X,
+		TargetY:       entry.Y,
+		Color:         c,
+		Status:        state.SendingToAPI,
+		Payload:       payload,
+	}
+}
+
+// launch entrega el paquete a un worker, respetando el límite de
+// concurrencia del modo de carga.
+func (r *Runner) launch(ctx context.Context, d *Device, packet *state.PacketState) {
+	endpoint, rng := r.Endpoints.Get(SensorKind(packet.Sensor)), d.Rand("packet/"+packet.ID)
+	// En el replay la demora ya está en los Offset grabados.
+	endpoint.NoDelay = r.Load.Enabled() || len(r.Replay) > 0
+	endpoint.Clock = r.Clock
+
+	slots := r.slots
+	r.workers.Add(1)
+	if slots == nil {
+		go func() {
+			defer r.workers.Done()
+			SendPOSTRequest(ctx, r.Client, endpoint, packet, rng, r.State)
+		}()
+		return
+	}
+	go func() {
+		defer r.workers.Done()
+		if !r.acquire(ctx, slots) {
+			return
+		}
+		defer r.release(slots)
+		SendPOSTRequest(ctx, r.Client, endpoint, packet, rng, r.State)
+	}()
+}
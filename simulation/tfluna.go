@@ -0,0 +1,134 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// TFLunaConfig describe la escena y las características del LiDAR.
+//
+// La amplitud de la señal sigue AmpAt1m*reflectividad/d² y se satura en
+// 65535. Según la hoja de datos del TF-Luna, si la amplitud es menor que
+// MinAmp, está saturada o la distancia sale de [MinRangeM, MaxRangeM], la
+// lectura no es confiable y se reporta InvalidDistanceCm.
+type TFLunaConfig struct {
+	TargetDistanceM   float64 `json:"target_distance_m"`
+	DistanceJitterM   float64 `json:"distance_jitter_m"`
+	MissProbability   float64 `json:"miss_probability"` // el haz no encuentra objetivo
+	Reflectivity      float64 `json:"reflectivity"`     // 0..1
+	ReflectivityStd   float64 `json:"reflectivity_std"`
+	AmpAt1m           float64 `json:"amp_at_1m"`
+	MinAmp            int     `json:"min_amp"`
+	MinRangeM         float64 `json:"min_range_m"`
+	MaxRangeM         float64 `json:"max_range_m"`
+	InvalidDistanceCm int     `json:"invalid_distance_cm"`
+	NoiseCm           float64 `json:"noise_cm"`
+	AmbientTempC      float64 `json:"ambient_temp_c"`
+	SelfHeatingC      float64 `json:"self_heating_c"` // cuánto sube con el uso
+	WarmupMinutes     float64 `json:"warmup_minutes"` // constante de tiempo
+	TempNoiseC        float64 `json:"temp_noise_c"`
+}
+
+// DefaultTFLunaConfig apunta a una pared clara a ~2.2 m.
+func DefaultTFLunaConfig() TFLunaConfig {
+	return TFLunaConfig{
+		TargetDistanceM:   2.2,
+		DistanceJitterM:   0.8,
+		MissProbability:   0.03,
+		Reflectivity:      0.6,
+		ReflectivityStd:   0.2,
+		AmpAt1m:           12000,
+		MinAmp:            100,
+		MinRangeM:         0.2,
+		MaxRangeM:         8,
+		InvalidDistanceCm: 0,
+		NoiseCm:           1.5,
+		AmbientTempC:      28,
+		SelfHeatingC:      22,
+		WarmupMinutes:     10,
+		TempNoiseC:        0.3,
+	}
+}
+
+// Validate rechaza las escenas sin rango útil o con valores negativos, con
+// las que todas las lecturas saldrían inválidas.
+func (c TFLunaConfig) Validate() error {
+	if c.MinRangeM < 0 || c.MaxRangeM <= c.MinRangeM {
+		return fmt.Errorf("tfluna: max_range_m (%g) debe ser mayor que min_range_m (%g)", c.MaxRangeM, c.MinRangeM)
+	}
+	if c.TargetDistanceM <= 0 || c.AmpAt1m <= 0 {
+		return fmt.Errorf("tfluna: target_distance_m y amp_at_1m deben ser positivos")
+	}
+	if c.MissProbability < 0 || c.MissProbability > 1 || c.Reflectivity < 0 || c.Reflectivity > 1 {
+		return fmt.Errorf("tfluna: miss_probability y reflectivity deben estar entre 0 y 1")
+	}
+	if c.DistanceJitterM < 0 || c.ReflectivityStd < 0 || c.NoiseCm < 0 || c.TempNoiseC < 0 ||
+		c.MinAmp < 0 || c.WarmupMinutes < 0 {
+		return fmt.Errorf("tfluna: distance_jitter_m, reflectivity_std, noise_cm, temp_noise_c, min_amp y warmup_minutes no pueden ser negativos")
+	}
+	return nil
+}
+
+// TFLunaModel genera lecturas del LiDAR con amplitud, rango y temperatura
+// relacionados entre sí.
+type TFLunaModel struct {
+	cfg   TFLunaConfig
+	start time.Time
+
+	// LastTrueDistanceM es la distancia real de la última muestra, aunque
+	// la lectura reportada haya sido inválida.
+	LastTrueDistanceM float64
+}
+
+// NewTFLunaModel crea el modelo; el tiempo de uso arranca en la primera muestra.
+func NewTFLunaModel(cfg TFLunaConfig) *TFLunaModel {
+	return &TFLunaModel{cfg: cfg}
+}
+
+// Sample produce la lectura en now.
+func (m *TFLunaModel) Sample(rng *rand.Rand, now time.Time) TFLunaData {
+	if m.start.IsZero() {
+		m.start = now
+	}
+	cfg := m.cfg
+
+	trueDist := cfg.TargetDistanceM + (rng.Float64()*2-1)*cfg.DistanceJitterM
+	if rng.Float64() < cfg.MissProbability {
+		trueDist = cfg.MaxRangeM * (1.2 + rng.Float64())
+	}
+	trueDist = math.Max(trueDist, 0.01)
+	m.LastTrueDistanceM = trueDist
+
+	reflectivity := clamp(cfg.Reflectivity+rng.NormFloat64()*cfg.ReflectivityStd, 0.01, 1)
+	amp := cfg.AmpAt1m * reflectivity / (trueDist * trueDist)
+	amp *= 1 + rng.NormFloat64()*0.05
+	strength := int(math.Min(math.Max(amp, 0), 65535))
+
+	distCm := int(math.Round(trueDist*100 + rng.NormFloat64()*cfg.NoiseCm))
+	if strength < cfg.MinAmp || strength == 65535 ||
+		trueDist < cfg.MinRangeM || trueDist > cfg.MaxRangeM {
+		distCm = cfg.InvalidDistanceCm
+	}
+
+	uptimeMin := now.Sub(m.start).Minutes()
+	temp := cfg.AmbientTempC
+	if cfg.WarmupMinutes > 0 {
+		temp += cfg.SelfHeatingC * (1 - math.Exp(-uptimeMin/cfg.WarmupMinutes))
+	}
+	temp += rng.NormFloat64() * cfg.TempNoiseC
+
+	return TFLunaData{
+		DistanciaCm: distCm,
+		DistanciaM:  float64(distCm) / 100.0,
+		FuerzaSenal: strength,
+		Temperatura: math.Round(temp*100) / 100,
+		Event:       true,
+		Timestamp:   now.Format(TimestampLayout),
+	}
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Min(math.Max(v, min), max)
+}
@@ -0,0 +1,283 @@
+package simulation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// StageKind decide qué hace la FSM cuando un paquete llega a una etapa.
+type StageKind string
+
+const (
+	// StageAPI es la etapa a la que envían los workers; la FSM la toma
+	// cuando la petición HTTP fue aceptada. Hay exactamente una.
+	StageAPI StageKind = "api"
+	// StageProcess retiene el paquete durante Process y sigue.
+	StageProcess StageKind = "process"
+	// StageQueue publica en el Broker y sigue cuando el consumidor confirma.
+	StageQueue StageKind = "queue"
+	// StageFrontend es un final: entrega el paquete (o espera el eco del
+	// WebSocket si hay confirmación).
+	StageFrontend StageKind = "frontend"
+)
+
+// BackpressurePolicy decide qué pasa cuando la fila de espera de una etapa
+// está llena.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock retiene el paquete en la etapa anterior (ocupando su
+	// lugar) hasta que haya espacio, así que la saturación se propaga hacia
+	// atrás. La etapa api no puede frenar a los workers: su fila crece.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDropNewest descarta el paquete que llega.
+	BackpressureDropNewest BackpressurePolicy = "drop-newest"
+	// BackpressureDropOldest descarta el primero de la fila y suma al que
+	// llega al final.
+	BackpressureDropOldest BackpressurePolicy = "drop-oldest"
+)
+
+// StageConfig es una etapa del pipeline.
+type StageConfig struct {
+	ID    string    `json:"id"`
+	Label string    `json:"label"`
+	Kind  StageKind `json:"kind"`
+	// Sprite es el icono a dibujar: "python", "rabbitmq", "websocket" o
+	// "monitor". Vacío (o desconocido) dibuja una caja con Label.
+	Sprite string  `json:"sprite"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	// Process es cuánto retiene el paquete (api y process). En las colas lo
+	// decide el consumidor del broker.
+	Process Distribution `json:"process"`
+	// Capacity es cuántos paquetes procesa a la vez (0 = sin límite). En las
+	// colas la capacidad la pone el broker.
+	Capacity int `json:"capacity"`
+	// QueueLimit es el largo máximo de la fila de espera (0 = sin límite) y
+	// Backpressure qué hacer cuando se llena ("" = la de la topología).
+	QueueLimit   int                `json:"queue_limit"`
+	Backpressure BackpressurePolicy `json:"backpressure"`
+}
+
+// EdgeConfig une dos etapas. Si una etapa tiene varias salidas, cada paquete
+// elige una al azar según Weight.
+type EdgeConfig struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Weight float64 `json:"weight"` // 0 = 1
+}
+
+// TopologyConfig describe el pipeline que recorre cada paquete tras la API.
+type TopologyConfig struct {
+	Stages []StageConfig `json:"stages"`
+	Edges  []EdgeConfig  `json:"edges"`
+	// Backpressure es la política de las etapas que no eligen una ("" =
+	// block).
+	Backpressure BackpressurePolicy `json:"backpressure"`
+}
+
+// DefaultTopology es el backend de Geova: API en Python → RabbitMQ →
+// WebSocket → frontend.
+func DefaultTopology() TopologyConfig {
+	return TopologyConfig{
+		Stages: []StageConfig{
+			{ID: "api", Label: "API Python", Kind: StageAPI, Sprite: "python", X: 250, Y: 200,
+				Process: Distribution{Kind: "fixed", MeanMs: 500}, Capacity: 4},
+			{ID: "rabbitmq", Label: "RabbitMQ", Kind: StageQueue, Sprite: "rabbitmq", X: 400, Y: 200,
+				QueueLimit: 12},
+			{ID: "websocket", Label: "API WebSocket", Kind: StageProcess, Sprite: "websocket", X: 550, Y: 200,
+				Process: Distribution{Kind: "fixed", MeanMs: 500}, Capacity: 4, QueueLimit: 12},
+			{ID: "frontend", Label: "Frontend", Kind: StageFrontend, Sprite: "monitor", X: 620, Y: 180},
+		},
+		Edges: []EdgeConfig{
+			{From: "api", To: "rabbitmq"},
+			{From: "rabbitmq", To: "websocket"},
+			{From: "websocket", To: "frontend"},
+		},
+	}
+}
+
+// LoadTopology lee una topología de un archivo JSON.
+func LoadTopology(path string) (TopologyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TopologyConfig{}, fmt.Errorf("no se pudo leer la topología '%s': %w", path, err)
+	}
+	var cfg TopologyConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return TopologyConfig{}, fmt.Errorf("topología inválida en '%s': %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Topology es una TopologyConfig validada. La usa sólo la FSM (un único
+// goroutine), así que no tiene lock.
+type Topology struct {
+	Stages []StageConfig
+
+	entry int
+	index map[string]int
+	out   map[string][]EdgeConfig
+	rng   *rand.Rand
+}
+
+// NewTopology valida cfg. Una configuración vacía usa DefaultTopology.
+func NewTopology(cfg TopologyConfig, rng *rand.Rand) (*Topology, error) {
+	if len(cfg.Stages) == 0 {
+		cfg = DefaultTopology()
+	}
+	switch cfg.Backpressure {
+	case "":
+		cfg.Backpressure = BackpressureBlock
+	case BackpressureBlock, BackpressureDropNewest, BackpressureDropOldest:
+	default:
+		return nil, fmt.Errorf("topología: backpressure '%s' desconocido (block, drop-newest o drop-oldest)", cfg.Backpressure)
+	}
+	t := &Topology{
+		Stages: cfg.Stages,
+		entry:  -1,
+		index:  make(map[string]int),
+		out:    make(map[string][]EdgeConfig),
+		rng:    rng,
+	}
+	for i := range t.Stages {
+		s := &t.Stages[i]
+		if s.ID == "" {
+			return nil, fmt.Errorf("topología: la etapa %d no tiene id", i+1)
+		}
+		if _, dup := t.index[s.ID]; dup {
+			return nil, fmt.Errorf("topología: etapa '%s' repetida", s.ID)
+		}
+		switch s.Kind {
+		case "":
+			s.Kind = StageProcess
+		case StageAPI:
+			if t.entry >= 0 {
+				return nil, fmt.Errorf("topología: hay más de una etapa de tipo api ('%s' y '%s')", t.Stages[t.entry].ID, s.ID)
+			}
+			t.entry = i
+		case StageProcess, StageQueue, StageFrontend:
+		default:
+			return nil, fmt.Errorf("topología: tipo '%s' desconocido en '%s' (api, process, queue o frontend)", s.Kind, s.ID)
+		}
+		if s.Label == "" {
+			s.Label = s.ID
+		}
+		if err := s.Process.Validate(); err != nil {
+			return nil, fmt.Errorf("topología: process de '%s': %w", s.ID, err)
+		}
+		if s.Capacity < 0 || s.QueueLimit < 0 {
+			return nil, fmt.Errorf("topología: capacity y queue_limit de '%s' no pueden ser negativos", s.ID)
+		}
+		switch s.Backpressure {
+		case "":
+			s.Backpressure = cfg.Backpressure
+		case BackpressureBlock, BackpressureDropNewest, BackpressureDropOldest:
+		default:
+			return nil, fmt.Errorf("topología: backpressure '%s' desconocido en '%s' (block, drop-newest o drop-oldest)", s.Backpressure, s.ID)
+		}
+		t.index[s.ID] = i
+	}
+	if t.entry < 0 {
+		return nil, fmt.Errorf("topología: falta la etapa de tipo api")
+	}
+
+	for _, e := range cfg.Edges {
+		from, ok := t.Stage(e.From)
+		if !ok {
+			return nil, fmt.Errorf("topología: arista desde '%s', que no existe", e.From)
+		}
+		if _, ok := t.Stage(e.To); !ok {
+			return nil, fmt.Errorf("topología: arista hacia '%s', que no existe", e.To)
+		}
+		if from.Kind == StageFrontend {
+			return nil, fmt.Errorf("topología: '%s' es un frontend y no puede tener salidas", e.From)
+		}
+		if e.Weight < 0 {
+			return nil, fmt.Errorf("topología: peso negativo en %s → %s", e.From, e.To)
+		}
+		if e.Weight == 0 {
+			e.Weight = 1
+		}
+		t.out[e.From] = append(t.out[e.From], e)
+	}
+	for _, s := range t.Stages {
+		if s.Kind != StageFrontend && len(t.out[s.ID]) == 0 {
+			return nil, fmt.Errorf("topología: '%s' no tiene salidas y no es un frontend", s.ID)
+		}
+	}
+	return t, nil
+}
+
+// Entry es la etapa de tipo api.
+func (t *Topology) Entry() StageConfig {
+	return t.Stages[t.entry]
+}
+
+// Stage busca una etapa por ID.
+func (t *Topology) Stage(id string) (StageConfig, bool) {
+	i, ok := t.index[id]
+	if !ok {
+		return StageConfig{}, false
+	}
+	return t.Stages[i], true
+}
+
+// Next elige la etapa siguiente a id según el peso de sus salidas.
+func (t *Topology) Next(id string) StageConfig {
+	edges := t.out[id]
+	total := 0.0
+	for _, e := range edges {
+		total += e.Weight
+	}
+	roll := t.rng.Float64() * total
+	for _, e := range edges {
+		if roll < e.Weight {
+			return t.Stages[t.index[e.To]]
+		}
+		roll -= e.Weight
+	}
+	return t.Stages[t.index[edges[len(edges)-1].To]]
+}
+
+// Feeds indica si alguna salida de id llega a una etapa de tipo kind.
+func (t *Topology) Feeds(id string, kind StageKind) bool {
+	for _, e := range t.out[id] {
+		if t.Stages[t.index[e.To]].Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessTime sortea cuánto retiene la etapa id a un paquete.
+func (t *Topology) ProcessTime(id string) time.Duration {
+	s, _ := t.Stage(id)
+	return s.Process.Sample(t.rng)
+}
+
+// StagesOf retorna los IDs de las etapas de tipo kind en orden.
+func (t *Topology) StagesOf(kind StageKind) []string {
+	var ids []string
+	for _, s := range t.Stages {
+		if s.Kind == kind {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+// IDs retorna los IDs de las etapas en orden.
+func (t *Topology) IDs() []string {
+	ids := make([]string, len(t.Stages))
+	for i, s := range t.Stages {
+		ids[i] = s.ID
+	}
+	return ids
+}
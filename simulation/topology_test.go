@@ -0,0 +1,90 @@
+package simulation
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewTopologyValidates(t *testing.T) {
+	api := StageConfig{ID: "api", Kind: StageAPI}
+	front := StageConfig{ID: "front", Kind: StageFrontend}
+	toFront := EdgeConfig{From: "api", To: "front"}
+
+	tests := []struct {
+		name string
+		cfg  TopologyConfig
+		want string // fragmento del error
+	}{
+		{"etapa sin id", TopologyConfig{Stages: []StageConfig{api, {Kind: StageFrontend}}}, "no tiene id"},
+		{"etapa repetida", TopologyConfig{Stages: []StageConfig{api, api}}, "repetida"},
+		{"dos api", TopologyConfig{Stages: []StageConfig{api, {ID: "api2", Kind: StageAPI}}}, "más de una etapa de tipo api"},
+		{"sin api", TopologyConfig{Stages: []StageConfig{front}}, "falta la etapa de tipo api"},
+		{"tipo desconocido", TopologyConfig{Stages: []StageConfig{api, {ID: "x", Kind: "cache"}}}, "tipo 'cache' desconocido"},
+		{"distribución desconocida", TopologyConfig{Stages: []StageConfig{api, {ID: "x", Process: Distribution{Kind: "gamma"}}}}, "process de 'x': distribución 'gamma' desconocida"},
+		{"capacidad negativa", TopologyConfig{Stages: []StageConfig{api, {ID: "x", Capacity: -1}}}, "no pueden ser negativos"},
+		{"backpressure de etapa", TopologyConfig{Stages: []StageConfig{api, {ID: "x", Backpressure: "drop-all"}}}, "backpressure 'drop-all' desconocido en 'x'"},
+		{"backpressure global", TopologyConfig{Stages: []StageConfig{api, front}, Backpressure: "drop-all"}, "backpressure 'drop-all' desconocido"},
+		{"arista desde inexistente", TopologyConfig{Stages: []StageConfig{api, front}, Edges: []EdgeConfig{toFront, {From: "x", To: "front"}}}, "arista desde 'x'"},
+		{"arista hacia inexistente", TopologyConfig{Stages: []StageConfig{api, front}, Edges: []EdgeConfig{{From: "api", To: "x"}}}, "arista hacia 'x'"},
+		{"salida de frontend", TopologyConfig{Stages: []StageConfig{api, front}, Edges: []EdgeConfig{toFront, {From: "front", To: "api"}}}, "no puede tener salidas"},
+		{"peso negativo", TopologyConfig{Stages: []StageConfig{api, front}, Edges: []EdgeConfig{{From: "api", To: "front", Weight: -1}}}, "peso negativo"},
+		{"etapa sin salida", TopologyConfig{Stages: []StageConfig{api, front}}, "'api' no tiene salidas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTopology(tt.cfg, rand.New(rand.NewSource(1)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewTopology = %v, se esperaba un error con %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTopologyDefaults(t *testing.T) {
+	topo, err := NewTopology(TopologyConfig{}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(topo.IDs(), ","); got != "api,rabbitmq,websocket,frontend" {
+		t.Errorf("topología por defecto: %s", got)
+	}
+	if topo.Entry().ID != "api" || topo.Next("api").ID != "rabbitmq" {
+		t.Errorf("entrada %s, siguiente %s", topo.Entry().ID, topo.Next("api").ID)
+	}
+	for _, s := range topo.Stages {
+		if s.Backpressure != BackpressureBlock {
+			t.Errorf("'%s' con backpressure %q, se esperaba block", s.ID, s.Backpressure)
+		}
+	}
+
+	// Las etapas sin tipo son process y sin label usan su id.
+	topo, err = NewTopology(TopologyConfig{
+		Stages: []StageConfig{{ID: "api", Kind: StageAPI}, {ID: "db"}, {ID: "front", Kind: StageFrontend}},
+		Edges:  []EdgeConfig{{From: "api", To: "db"}, {From: "db", To: "front"}},
+	}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db, _ := topo.Stage("db"); db.Kind != StageProcess || db.Label != "db" {
+		t.Errorf("db: tipo %s, label %q", db.Kind, db.Label)
+	}
+}
+
+func TestTopologyNextWeights(t *testing.T) {
+	topo, err := NewTopology(TopologyConfig{
+		Stages: []StageConfig{{ID: "api", Kind: StageAPI}, {ID: "a", Kind: StageFrontend}, {ID: "b", Kind: StageFrontend}},
+		Edges:  []EdgeConfig{{From: "api", To: "a", Weight: 3}, {From: "api", To: "b"}},
+	}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[topo.Next("api").ID]++
+	}
+	// Peso 3 contra 1 (el 0 vale 1): ~3000 y ~1000.
+	if counts["a"] < 2800 || counts["a"] > 3200 {
+		t.Errorf("reparto %v, se esperaba ~3000/1000", counts)
+	}
+}
@@ -0,0 +1,344 @@
+package simulation
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"geova-simulation/state"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tipos de span de OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// Códigos de estado de OTLP.
+const (
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+// Tracer arma una traza por paquete y la escribe al terminar como una línea
+// OTLP-JSON (un ExportTraceServiceRequest por línea, como el exportador de
+// archivo del OpenTelemetry Collector). Implementa state.Observer.
+//
+// Cada traza tiene un span raíz "packet" con tres hijos: "generate" (la
+// lectura del sensor), "worker" (serialize, delay, cada intento http y los
+// backoff entre intentos) y "pipeline" (un span por estado de la FSM desde
+// ArrivedAtAPI hasta el final, con el ID de la etapa de la topología).
+type Tracer struct {
+	mutex  sync.Mutex
+	file   *os.File
+	enc    *json.Encoder
+	path   string
+	failed bool
+	traces map[string]*packetTrace // traceKey → traza en curso
+}
+
+type packetTrace struct {
+	traceID  string
+	spans    []otlpSpan // spans[0] es la raíz
+	worker   int        // índices en spans; -1 = cerrado
+	pipeline int
+	stage    int
+}
+
+// NewTracer crea (o vacía) el archivo de trazas.
+func NewTracer(path string) (*Tracer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el archivo de trazas '%s': %w", path, err)
+	}
+	return &Tracer{
+		file:   f,
+		enc:    json.NewEncoder(f),
+		path:   path,
+		traces: make(map[string]*packetTrace),
+	}, nil
+}
+
+// PacketCreated abre la traza. El ID de traza sale del ID de correlación,
+// así que repetir la corrida con -seed repite también las trazas.
+func (t *Tracer) PacketCreated(p *state.PacketState) {
+	now := time.Now()
+	key := traceKey(p)
+	sum := sha256.Sum256([]byte(key))
+	tr := &packetTrace{traceID: hex.EncodeToString(sum[:16]), stage: -1, pipeline: -1}
+
+	start := now
+	if !p.GenStart.IsZero() {
+		start = p.GenStart
+	}
+	tr.open(-1, "packet", spanKindInternal, start,
+		stringAttr("geova.packet.id", p.ID),
+		stringAttr("geova.request_id", p.CorrelationID),
+		stringAttr("geova.sensor", p.Sensor),
+		intAttr("geova.project.id", p.ProjectID),
+		stringAttr("geova.device.id", p.DeviceID),
+		intAttr("geova.batch", p.Batch))
+	if p.RetryOf != "" {
+		tr.spans[0].Attributes = append(tr.spans[0].Attributes, stringAttr("geova.retry_of", p.RetryOf))
+	}
+	if !p.GenStart.IsZero() {
+		tr.spans[tr.open(0, "generate", spanKindInternal, p.GenStart)].end = p.GenEnd
+	}
+	tr.worker = tr.open(0, "worker", spanKindInternal, now)
+
+	t.mutex.Lock()
+	t.traces[key] = tr
+	t.mutex.Unlock()
+}
+
+// Phase agrega un tramo del worker ya terminado.
+func (t *Tracer) Phase(p *state.PacketState, name string, start, end time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tr, ok := t.traces[traceKey(p)]
+	if !ok || tr.worker < 0 {
+		return
+	}
+	tr.spans[tr.open(tr.worker, name, spanKindInternal, start)].end = end
+}
+
+// AttemptDone agrega el intento HTTP como span de cliente.
+func (t *Tracer) AttemptDone(p *state.PacketState, attempt, statusCode int, latency time.Duration, err error) {
+	end := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tr, ok := t.traces[traceKey(p)]
+	if !ok || tr.worker < 0 {
+		return
+	}
+	i := tr.open(tr.worker, "http", spanKindClient, end.Add(-latency),
+		intAttr("geova.attempt", attempt),
+		stringAttr("geova.request_id", p.CorrelationID))
+	s := &tr.spans[i]
+	s.end = end
+	if statusCode != 0 {
+		s.Attributes = append(s.Attributes, intAttr("http.response.status_code", statusCode))
+	}
+	switch {
+	case err != nil:
+		s.Status = otlpStatus{Code: spanStatusError, Message: err.Error()}
+	case statusCode >= 400:
+		s.Status = otlpStatus{Code: spanStatusError, Message: fmt.Sprintf("HTTP %d", statusCode)}
+	}
+}
+
+// Transition cierra la etapa anterior y abre la siguiente; en un estado
+// final cierra la traza y la escribe.
+func (t *Tracer) Transition(p *state.PacketState, from, to state.PacketStatus) {
+	now := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tr, ok := t.traces[traceKey(p)]
+	if !ok {
+		return
+	}
+	tr.close(&tr.stage, now)
+
+	if p.Finished() {
+		tr.close(&tr.worker, now)
+		tr.close(&tr.pipeline, now)
+		root := &tr.spans[0]
+		root.end = now
+		root.Attributes = append(root.Attributes, stringAttr("geova.final_status", to.String()))
+		switch to {
+		case state.Done:
+			root.Status = otlpStatus{Code: spanStatusOK}
+		case state.Error, state.Lost, state.Dropped:
+			msg := to.String()
+			if p.FailureReason != "" {
+				msg = p.FailureReason
+			} else if p.LastError != "" {
+				msg = p.LastError
+			} else if p.LastStatusCode >= 400 {
+				msg = fmt.Sprintf("HTTP %d", p.LastStatusCode)
+			}
+			root.Status = otlpStatus{Code: spanStatusError, Message: msg}
+		}
+		delete(t.traces, traceKey(p))
+		t.export(tr)
+		return
+	}
+
+	switch {
+	case to == state.Retrying && tr.worker >= 0:
+		tr.stage = tr.open(tr.worker, "backoff", spanKindInternal, now)
+	case to == state.ArrivedAtAPI:
+		tr.close(&tr.worker, now)
+		tr.pipeline = tr.open(0, "pipeline", spanKindInternal, now)
+		fallthrough
+	case tr.pipeline >= 0:
+		name := to.String()
+		if p.Stage != "" {
+			name += " " + p.Stage
+		}
+		tr.stage = tr.open(tr.pipeline, name, spanKindInternal, now)
+	}
+}
+
+// Flush escribe las trazas sin terminar con el estado en que quedaron. Se
+// llama al empezar una corrida, que descarta los paquetes de la anterior.
+// Es seguro sobre nil.
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.flush()
+}
+
+// Close escribe las trazas sin terminar y cierra el archivo. Es seguro sobre
+// nil.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.flush()
+	return t.file.Close()
+}
+
+func (t *Tracer) flush() {
+	now := time.Now()
+	for id, tr := range t.traces {
+		tr.close(&tr.stage, now)
+		tr.close(&tr.worker, now)
+		tr.close(&tr.pipeline, now)
+		tr.spans[0].end = now
+		tr.spans[0].Attributes = append(tr.spans[0].Attributes, stringAttr("geova.final_status", "unfinished"))
+		delete(t.traces, id)
+		t.export(tr)
+	}
+}
+
+// traceKey identifica la traza de p. Es el ID de correlación, que incluye la
+// corrida: los IDs de paquete se reinician en cada corrida y los paquetes que
+// quedaron en curso pisarían sus trazas.
+func traceKey(p *state.PacketState) string {
+	if p.CorrelationID != "" {
+		return p.CorrelationID
+	}
+	return p.ID
+}
+
+// open agrega un span hijo de parent (-1 = raíz) y retorna su índice.
+func (tr *packetTrace) open(parent int, name string, kind int, start time.Time, attrs ...otlpAttr) int {
+	s := otlpSpan{
+		TraceID:    tr.traceID,
+		SpanID:     tr.spanID(len(tr.spans)),
+		Name:       name,
+		Kind:       kind,
+		Attributes: attrs,
+		start:      start,
+	}
+	if parent >= 0 {
+		s.ParentSpanID = tr.spans[parent].SpanID
+	}
+	tr.spans = append(tr.spans, s)
+	return len(tr.spans) - 1
+}
+
+// close termina el span *i si está abierto y lo marca como cerrado.
+func (tr *packetTrace) close(i *int, end time.Time) {
+	if *i >= 0 {
+		tr.spans[*i].end = end
+		*i = -1
+	}
+}
+
+func (tr *packetTrace) spanID(n int) string {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n))
+	sum := sha256.Sum256(append([]byte(tr.traceID), buf[:]...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (t *Tracer) export(tr *packetTrace) {
+	for i := range tr.spans {
+		s := &tr.spans[i]
+		if s.end.IsZero() {
+			s.end = tr.spans[0].end
+		}
+		s.StartTimeUnixNano = strconv.FormatInt(s.start.UnixNano(), 10)
+		s.EndTimeUnixNano = strconv.FormatInt(s.end.UnixNano(), 10)
+	}
+	req := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{stringAttr("service.name", "geova-simulation")}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "geova-simulation/simulation"},
+			Spans: tr.spans,
+		}},
+	}}}
+	if err := t.enc.Encode(req); err != nil && !t.failed {
+		// Se avisa una sola vez para no inundar la consola.
+		t.failed = true
+		fmt.Printf("[TRAZAS] Error al escribir '%s': %v\n", t.path, err)
+	}
+}
+
+// Estructuras de OTLP/JSON (opentelemetry-proto, trace/v1). Los IDs van en
+// hexadecimal y los enteros de 64 bits como strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+
+	start, end time.Time
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func stringAttr(key, value string) otlpAttr {
+	return otlpAttr{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+func intAttr(key string, value int) otlpAttr {
+	return otlpAttr{Key: key, Value: map[string]string{"intValue": strconv.Itoa(value)}}
+}
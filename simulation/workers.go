@@ -2,108 +2,179 @@ package simulation
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"geova-simulation/state"
-	"image/color"
+	"io"
 	"math/rand"
 	"net/http"
 	"time"
 )
 
-func GenerateRandomIMXData() IMXData {
-	return IMXData{
-		IDProject:      4,
-		Resolution:     "640x480",
-		Luminosidad:    5.0 + rand.Float64()*10.0,
-		Nitidez:        4.0 + rand.Float64()*2.0,
-		LaserDetectado: rand.Intn(2) == 1,
-		CalidadFrame:   20.0,
-		Confiabilidad:  0.8 + rand.Float64()*0.2,
-		Event:          true,
-		Timestamp:      time.Now().Format("2006-01-02 15:04:05"),
-	}
-}
-
-func GenerateRandomMPUData(tilt float64) MPUData {
-	return MPUData{
-		IDProject: 4,
-		Ax:        0.1 + rand.Float64()*0.1,
-		Ay:        -0.05 + rand.Float64()*0.1,
-		Az:        9.8 + rand.Float64()*0.1,
-		Gx:        0.01 + rand.Float64()*0.02,
-		Gy:        0.02 + rand.Float64()*0.02,
-		Gz:        0.03 + rand.Float64()*0.02,
-		Roll:      tilt,
-		Pitch:     0.5 + rand.Float64()*1.0,
-		Apertura:  tilt * 1.5,
-		Event:     true,
-		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
-	}
-}
+// SendPOSTRequest registra packet en visState y envía su Payload al
+// endpoint, reintentando según endpoint.Retry. Al reintentar, el paquete
+// vuelve hacia su posición inicial.
+func SendPOSTRequest(ctx context.Context, client *http.Client, endpoint Endpoint,
+	packet *state.PacketState, rng *rand.Rand, visState *state.VisualState) {
 
-func GenerateRandomTFLunaData() TFLunaData {
-	distCm := 150 + rand.Intn(150)
-	return TFLunaData{
-		IDProject:   4,
-		DistanciaCm: distCm,
-		DistanciaM:  float64(distCm) / 100.0,
-		FuerzaSenal: 5000 + rand.Intn(1000),
-		Temperatura: 50.0 + rand.Float64()*5.0,
-		Event:       true,
-		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
+	policy := endpoint.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
 	}
-}
-
-func SendPOSTRequest(url string, payload interface{}, packetID string,
-	visState *state.VisualState, startY float64, c color.Color) {
+	packetID := packet.ID
+	tag := packet.LogTag()
+	startX, startY := packet.X, packet.Y
+	apiX, apiY := packet.TargetX, packet.TargetY
 
 	visState.Mutex.Lock()
-	packet := &state.PacketState{
-		ID:              packetID,
-		Active:          true,
-		X:               80.0,
-		Y:               startY,
-		TargetX:         250.0,
-		TargetY:         200.0,
-		Color:           c,
-		Status:          state.SendingToAPI,
-		Payload:         payload,
-		ProcessingTimer: 0,
-	}
+	packet.Attempt = 1
+	packet.MaxAttempts = policy.MaxAttempts
+	packet.CreatedAt = time.Now()
 	visState.Packets[packetID] = packet
+	visState.Stats.Created++
+	if visState.Observer != nil {
+		visState.Observer.PacketCreated(packet)
+	}
 	visState.Mutex.Unlock()
 
-	jsonData, err := json.Marshal(payload)
+	serializeStart := time.Now()
+	jsonData, err := json.Marshal(packet.Payload)
+	phase(visState, packet, "serialize", serializeStart)
 	if err != nil {
-		fmt.Printf("[%s] Error al serializar JSON: %v\n", packetID, err)
+		fmt.Printf("[%s] Error al serializar JSON: %v\n", tag, err)
 		visState.Mutex.Lock()
-		visState.Packets[packetID].Status = state.Error
+		packet.FailureReason = fmt.Sprintf("no se pudo serializar: %v", err)
+		visState.SetStatus(packet, state.Error)
 		visState.Mutex.Unlock()
 		return
 	}
 
-	time.Sleep(time.Duration(500+rand.Intn(500)) * time.Millisecond)
+	url := endpoint.URL()
+	method := endpoint.HTTPMethod()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			visState.Mutex.Lock()
+			packet.Attempt = attempt
+			visState.SetStatus(packet, state.SendingToAPI)
+			packet.TargetX = apiX
+			packet.TargetY = apiY
+			visState.Mutex.Unlock()
+		}
 
-	fmt.Printf("[%s] Enviando POST a %s\n", packetID, url)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+		if !endpoint.NoDelay {
+			delayStart := time.Now()
+			err := endpoint.Clock.Sleep(ctx, time.Duration(500+rng.Intn(500))*time.Millisecond)
+			phase(visState, packet, "delay", delayStart)
+			if err != nil {
+				markCancelled(visState, packet)
+				return
+			}
+		}
 
+		fmt.Printf("[%s] Enviando %s a %s (intento %d/%d)\n", tag, method, url, attempt, policy.MaxAttempts)
+		sentAt := time.Now()
+		statusCode, err := doRequest(ctx, client, endpoint, jsonData, packet.CorrelationID)
+
+		if err != nil && ctx.Err() != nil {
+			markCancelled(visState, packet)
+			return
+		}
+		latency := time.Since(sentAt)
+		visState.Mutex.Lock()
+		packet.LastStatusCode = statusCode
+		packet.LastError = ""
+		if err != nil {
+			packet.LastError = err.Error()
+		}
+		visState.Mutex.Unlock()
+		visState.Latency.Record(packet.Sensor, latency)
+		if visState.Observer != nil {
+			visState.Observer.AttemptDone(packet, attempt, statusCode, latency, err)
+		}
+
+		if err == nil && statusCode < 400 {
+			fmt.Printf("[%s] ✓ Petición exitosa (HTTP %d)\n", tag, statusCode)
+			visState.Mutex.Lock()
+			visState.SetStatus(packet, state.ArrivedAtAPI)
+			visState.Mutex.Unlock()
+			return
+		}
+
+		if err != nil {
+			fmt.Printf("[%s] Error en HTTP: %v\n", tag, err)
+		} else {
+			fmt.Printf("[%s] Error HTTP %d\n", tag, statusCode)
+		}
+
+		if attempt >= policy.MaxAttempts || !policy.ShouldRetry(statusCode, err) {
+			reason := fmt.Sprintf("HTTP %d", statusCode)
+			if err != nil {
+				reason = err.Error()
+			}
+			if attempt >= policy.MaxAttempts {
+				reason = fmt.Sprintf("%s tras %d intentos", reason, attempt)
+			} else {
+				reason += " (no reintentable)"
+			}
+			visState.Mutex.Lock()
+			packet.FailureReason = reason
+			visState.SetStatus(packet, state.Error)
+			visState.Mutex.Unlock()
+			return
+		}
+
+		wait := policy.Backoff(attempt, rng)
+		fmt.Printf("[%s] Reintentando en %v\n", tag, wait.Round(time.Millisecond))
+
+		visState.Mutex.Lock()
+		visState.SetStatus(packet, state.Retrying)
+		packet.TargetX = startX
+		packet.TargetY = startY
+		visState.Mutex.Unlock()
+
+		if err := endpoint.Clock.Sleep(ctx, wait); err != nil {
+			markCancelled(visState, packet)
+			return
+		}
+	}
+}
+
+// phase avisa al Observer del tramo que empezó en start y termina ahora.
+func phase(visState *state.VisualState, packet *state.PacketState, name string, start time.Time) {
+	if visState.Observer != nil {
+		visState.Observer.Phase(packet, name, start, time.Now())
+	}
+}
+
+func markCancelled(visState *state.VisualState, packet *state.PacketState) {
+	fmt.Printf("[%s] Cancelado al detener la simulación\n", packet.LogTag())
 	visState.Mutex.Lock()
-	defer visState.Mutex.Unlock()
+	visState.SetStatus(packet, state.Cancelled)
+	visState.Mutex.Unlock()
+}
 
+// doRequest envía el cuerpo al endpoint y retorna el código HTTP. El cuerpo
+// de la respuesta se descarta para poder reutilizar la conexión.
+func doRequest(ctx context.Context, client *http.Client, endpoint Endpoint, body []byte, requestID string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, endpoint.HTTPMethod(), endpoint.URL(), bytes.NewBuffer(body))
 	if err != nil {
-		fmt.Printf("[%s] Error en HTTP: %v\n", packetID, err)
-		visState.Packets[packetID].Status = state.Error
-		return
+		return 0, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		fmt.Printf("[%s] Error HTTP %d\n", packetID, resp.StatusCode)
-		visState.Packets[packetID].Status = state.Error
-		return
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
 	}
 
-	fmt.Printf("[%s] ✓ Petición exitosa (HTTP %d)\n", packetID, resp.StatusCode)
-	visState.Packets[packetID].Status = state.ArrivedAtAPI
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
 }
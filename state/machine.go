@@ -0,0 +1,167 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Guard decide si una transición permitida puede hacerse ahora; el error
+// explica por qué no.
+type Guard func(p *PacketState) error
+
+// Action corre al entrar o salir de un estado, con Mutex tomado.
+type Action func(vs *VisualState, p *PacketState)
+
+// Machine es la tabla de transiciones de un paquete: qué cambios de estado
+// están permitidos, con qué guardas, y qué acciones corren al entrar y
+// salir de cada estado. Se arma una vez y después sólo se lee.
+type Machine struct {
+	rules map[PacketStatus]map[PacketStatus]Guard
+	enter map[PacketStatus][]Action
+	exit  map[PacketStatus][]Action
+}
+
+// NewMachine crea una máquina sin transiciones.
+func NewMachine() *Machine {
+	return &Machine{
+		rules: make(map[PacketStatus]map[PacketStatus]Guard),
+		enter: make(map[PacketStatus][]Action),
+		exit:  make(map[PacketStatus][]Action),
+	}
+}
+
+// Allow permite pasar de from a cada uno de to si guard (que puede ser nil)
+// lo acepta.
+func (m *Machine) Allow(from PacketStatus, guard Guard, to ...PacketStatus) *Machine {
+	if m.rules[from] == nil {
+		m.rules[from] = make(map[PacketStatus]Guard)
+	}
+	for _, t := range to {
+		m.rules[from][t] = guard
+	}
+	return m
+}
+
+// OnEnter agrega una acción al entrar a s.
+func (m *Machine) OnEnter(s PacketStatus, a Action) *Machine {
+	m.enter[s] = append(m.enter[s], a)
+	return m
+}
+
+// OnExit agrega una acción al salir de s.
+func (m *Machine) OnExit(s PacketStatus, a Action) *Machine {
+	m.exit[s] = append(m.exit[s], a)
+	return m
+}
+
+// Check retorna por qué p no puede pasar de from a to (nil si puede).
+func (m *Machine) Check(p *PacketState, from, to PacketStatus) error {
+	guard, ok := m.rules[from][to]
+	if !ok {
+		return fmt.Errorf("no está en la tabla")
+	}
+	if guard != nil {
+		return guard(p)
+	}
+	return nil
+}
+
+// Next lista los estados a los que se puede pasar desde from.
+func (m *Machine) Next(from PacketStatus) []PacketStatus {
+	out := make([]PacketStatus, 0, len(m.rules[from]))
+	for to := range m.rules[from] {
+		out = append(out, to)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// StatusChange es una entrada del historial de un paquete.
+type StatusChange struct {
+	At time.Time
+	// SimAt es VisualState.Clock.Now() al hacer el cambio.
+	SimAt    time.Duration
+	From, To PacketStatus
+	Stage    string // etapa de la topología al hacer el cambio
+}
+
+// maxHistory acota el historial de cada paquete; en topologías con ciclos
+// se descartan las entradas más viejas.
+const maxHistory = 64
+
+// Guardas de DefaultMachine.
+func hasStage(p *PacketState) error {
+	if p.Stage == "" {
+		return fmt.Errorf("el paquete no tiene etapa")
+	}
+	return nil
+}
+
+func atTarget(p *PacketState) error {
+	if p.X != p.TargetX || p.Y != p.TargetY {
+		return fmt.Errorf("todavía no llegó a %s", p.Stage)
+	}
+	return hasStage(p)
+}
+
+func attemptsLeft(p *PacketState) error {
+	if p.Attempt >= p.MaxAttempts {
+		return fmt.Errorf("agotó los %d intentos", p.MaxAttempts)
+	}
+	return nil
+}
+
+func timerExpired(p *PacketState) error {
+	if p.ProcessingTimer > 0 {
+		return fmt.Errorf("faltan %v", p.ProcessingTimer)
+	}
+	return nil
+}
+
+// DefaultMachine es el ciclo de vida de un paquete:
+//
+//	SendingToAPI ⇄ Retrying           (worker: HTTP y backoff)
+//	SendingToAPI → ArrivedAtAPI       (worker: la API aceptó)
+//	ArrivedAtAPI → ProcessingAtStage  (FSM: entra a la etapa api)
+//	ProcessingAtStage ⇄ SendingToStage
+//	SendingToStage → AwaitingEcho | Done  (frontend)
+//	AwaitingEcho → Done | Lost
+//
+// Con la etapa ocupada, ArrivedAtAPI y SendingToStage pasan por
+// WaitingAtStage (de donde salen a ProcessingAtStage o Dropped). Error y
+// Cancelled vienen del worker. Las acciones de entrada llevan
+// los contadores de Stats.
+func DefaultMachine() *Machine {
+	m := NewMachine()
+	m.Allow(SendingToAPI, nil, ArrivedAtAPI, Error, Cancelled)
+	m.Allow(SendingToAPI, attemptsLeft, Retrying)
+	m.Allow(Retrying, nil, SendingToAPI, Cancelled)
+	m.Allow(ArrivedAtAPI, hasStage, ProcessingAtStage, WaitingAtStage, Dropped)
+	m.Allow(ProcessingAtStage, hasStage, SendingToStage)
+	m.Allow(SendingToStage, atTarget, ProcessingAtStage, WaitingAtStage, AwaitingEcho, Done, Dropped)
+	m.Allow(WaitingAtStage, hasStage, ProcessingAtStage, Dropped)
+	m.Allow(AwaitingEcho, nil, Done)
+	m.Allow(AwaitingEcho, timerExpired, Lost)
+
+	m.OnEnter(ArrivedAtAPI, func(vs *VisualState, _ *PacketState) { vs.Stats.Succeeded++ })
+	m.OnEnter(Retrying, func(vs *VisualState, _ *PacketState) { vs.Stats.Retries++ })
+	m.OnEnter(Error, func(vs *VisualState, p *PacketState) {
+		vs.Stats.Failed++
+		vs.DeadLetters = append(vs.DeadLetters, p.ID)
+	})
+	m.OnEnter(Cancelled, func(vs *VisualState, _ *PacketState) { vs.Stats.Cancelled++ })
+	m.OnEnter(Lost, func(vs *VisualState, _ *PacketState) { vs.Stats.Lost++ })
+	m.OnEnter(Dropped, func(vs *VisualState, p *PacketState) {
+		vs.Stats.Dropped++
+		p.Active = false
+	})
+	m.OnEnter(Done, func(vs *VisualState, p *PacketState) {
+		vs.Stats.Delivered++
+		p.Active = false
+	})
+	m.OnExit(ProcessingAtStage, func(_ *VisualState, p *PacketState) { p.ProcessingTimer = 0 })
+	return m
+}
+
+var defaultMachine = DefaultMachine()
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultMachineTransitions(t *testing.T) {
+	ready := func(p *PacketState) {}
+	tests := []struct {
+		name     string
+		from, to PacketStatus
+		setup    func(p *PacketState)
+		allowed  bool
+	}{
+		{"envío aceptado", SendingToAPI, ArrivedAtAPI, ready, true},
+		{"envío fallido", SendingToAPI, Error, ready, true},
+		{"reintento con intentos", SendingToAPI, Retrying, ready, true},
+		{"reintento sin intentos", SendingToAPI, Retrying, func(p *PacketState) { p.Attempt = 3 }, false},
+		{"vuelve a enviar", Retrying, SendingToAPI, ready, true},
+		{"entra a la api", ArrivedAtAPI, ProcessingAtStage, ready, true},
+		{"entra sin etapa", ArrivedAtAPI, ProcessingAtStage, func(p *PacketState) { p.Stage = "" }, false},
+		{"llega a la etapa", SendingToStage, ProcessingAtStage, ready, true},
+		{"llega antes de tiempo", SendingToStage, ProcessingAtStage, func(p *PacketState) { p.X = 0 }, false},
+		{"fila a etapa", WaitingAtStage, ProcessingAtStage, ready, true},
+		{"fila descartada", WaitingAtStage, Dropped, ready, true},
+		{"eco recibido", AwaitingEcho, Done, ready, true},
+		{"eco vencido", AwaitingEcho, Lost, ready, true},
+		{"eco pendiente", AwaitingEcho, Lost, func(p *PacketState) { p.ProcessingTimer = 1 }, false},
+		{"no está en la tabla", SendingToAPI, Done, ready, false},
+		{"desde un final", Done, SendingToAPI, ready, false},
+		{"Error es final", Error, Retrying, ready, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := &VisualState{}
+			p := &PacketState{ID: "tfluna_1", Status: tt.from, Stage: "api",
+				X: 10, Y: 10, TargetX: 10, TargetY: 10, MaxAttempts: 3, Attempt: 1}
+			tt.setup(p)
+
+			got := vs.SetStatus(p, tt.to)
+			if got != tt.allowed {
+				t.Fatalf("%s → %s: SetStatus = %v, se esperaba %v", tt.from, tt.to, got, tt.allowed)
+			}
+			if tt.allowed {
+				if p.Status != tt.to || len(p.History) != 1 || vs.Stats.Rejected != 0 {
+					t.Errorf("%s → %s: estado %s, historial %d, rechazadas %d",
+						tt.from, tt.to, p.Status, len(p.History), vs.Stats.Rejected)
+				}
+			} else if p.Status != tt.from || len(p.History) != 0 || vs.Stats.Rejected != 1 {
+				t.Errorf("%s → %s rechazada: estado %s, historial %d, rechazadas %d",
+					tt.from, tt.to, p.Status, len(p.History), vs.Stats.Rejected)
+			}
+		})
+	}
+}
+
+func TestDefaultMachineGuards(t *testing.T) {
+	m := DefaultMachine()
+	tests := []struct {
+		name     string
+		p        PacketState
+		from, to PacketStatus
+		want     string
+	}{
+		{"sin etapa", PacketState{}, WaitingAtStage, Dropped, "el paquete no tiene etapa"},
+		{"sin llegar", PacketState{Stage: "db", TargetX: 5}, SendingToStage, Done, "todavía no llegó a db"},
+		{"sin intentos", PacketState{Attempt: 2, MaxAttempts: 2}, SendingToAPI, Retrying, "agotó los 2 intentos"},
+		{"timer corriendo", PacketState{ProcessingTimer: 1500 * time.Millisecond}, AwaitingEcho, Lost, "faltan 1.5s"},
+		{"fuera de tabla", PacketState{}, Idle, Done, "no está en la tabla"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Check(&tt.p, tt.from, tt.to)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Check = %v, se esperaba %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultMachineActions(t *testing.T) {
+	vs := &VisualState{}
+	p := &PacketState{ID: "mpu_1", Status: SendingToAPI, Stage: "api", Active: true, MaxAttempts: 1, Attempt: 1}
+
+	vs.SetStatus(p, Error)
+	if vs.Stats.Failed != 1 || len(vs.DeadLetters) != 1 || vs.DeadLetters[0] != "mpu_1" {
+		t.Errorf("al entrar a Error: Failed %d, DeadLetters %v", vs.Stats.Failed, vs.DeadLetters)
+	}
+
+	q := &PacketState{ID: "mpu_2", Status: ProcessingAtStage, Stage: "api", Active: true, ProcessingTimer: 10}
+	vs.SetStatus(q, SendingToStage)
+	if q.ProcessingTimer != 0 {
+		t.Errorf("al salir de ProcessingAtStage el timer quedó en %v", q.ProcessingTimer)
+	}
+	vs.SetStatus(q, Done)
+	if vs.Stats.Delivered != 1 || q.Active {
+		t.Errorf("al entrar a Done: Delivered %d, Active %v", vs.Stats.Delivered, q.Active)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	vs := &VisualState{}
+	p := &PacketState{Status: ProcessingAtStage, Stage: "api"}
+	for i := 0; i < maxHistory+10; i++ {
+		if p.Status == ProcessingAtStage {
+			vs.SetStatus(p, SendingToStage)
+		} else {
+			vs.SetStatus(p, ProcessingAtStage)
+		}
+	}
+	if len(p.History) != maxHistory {
+		t.Fatalf("historial de %d entradas, se esperaba %d", len(p.History), maxHistory)
+	}
+	if last := p.History[len(p.History)-1]; last.To != p.Status {
+		t.Errorf("la última entrada es %s → %s, el paquete está en %s", last.From, last.To, p.Status)
+	}
+}
@@ -0,0 +1,140 @@
+package state
+
+import (
+	"geova-simulation/metrics"
+	"sort"
+	"time"
+)
+
+// RetentionConfig acota cuántos paquetes terminados quedan en
+// VisualState.Packets; los que sobran se resumen en Retired.
+type RetentionConfig struct {
+	// MaxPackets es cuántos terminados se conservan como máximo, los más
+	// nuevos (0 = sin límite). Incluye los de dead letters.
+	MaxPackets int `json:"max_packets"`
+	// MaxAgeMs es cuánto tiempo simulado se conserva un paquete después de
+	// terminar (0 = sin límite). No aplica a los de dead letters, que
+	// esperan al usuario.
+	MaxAgeMs int `json:"max_age_ms"`
+	// MaxDeadLetters es cuántos paquetes esperan en dead letters como
+	// máximo, los más nuevos (0 = sin límite).
+	MaxDeadLetters int `json:"max_dead_letters"`
+}
+
+// DefaultRetentionConfig conserva los últimos 500 terminados, hasta un
+// minuto cada uno, y 100 en dead letters.
+func DefaultRetentionConfig() RetentionConfig {
+	return RetentionConfig{MaxPackets: 500, MaxAgeMs: 60000, MaxDeadLetters: 100}
+}
+
+// MaxAge es MaxAgeMs como duración.
+func (c RetentionConfig) MaxAge() time.Duration {
+	return time.Duration(c.MaxAgeMs) * time.Millisecond
+}
+
+// Retired es el resumen de los paquetes que la retención sacó de Packets.
+type Retired struct {
+	Packets  int
+	ByStatus map[PacketStatus]int
+	BySensor map[string]int
+	// Lifetime va de CreatedAt al estado final.
+	Lifetime *metrics.Histogram
+}
+
+func (r *Retired) add(p *PacketState, end time.Time) {
+	if r.ByStatus == nil {
+		r.ByStatus = make(map[PacketStatus]int)
+		r.BySensor = make(map[string]int)
+		r.Lifetime = &metrics.Histogram{}
+	}
+	r.Packets++
+	r.ByStatus[p.Status]++
+	r.BySensor[p.Sensor]++
+	if !p.CreatedAt.IsZero() {
+		r.Lifetime.Record(end.Sub(p.CreatedAt))
+	}
+}
+
+// FinishedAt es cuándo el paquete llegó a su estado final (cero si no
+// terminó).
+func (p *PacketState) FinishedAt() time.Time {
+	if !p.Finished() || len(p.History) == 0 {
+		return time.Time{}
+	}
+	return p.History[len(p.History)-1].At
+}
+
+// finishedSim es FinishedAt en tiempo simulado.
+func (p *PacketState) finishedSim() time.Duration {
+	if !p.Finished() || len(p.History) == 0 {
+		return 0
+	}
+	return p.History[len(p.History)-1].SimAt
+}
+
+// Evict aplica Retention: saca de Packets los terminados que pasaron MaxAge
+// a la hora simulada now, los de dead letters que pasan de MaxDeadLetters y,
+// si siguen sobrando más de MaxPackets, los más viejos. Retorna cuántos
+// sacó. Se llama con Mutex tomado.
+func (vs *VisualState) Evict(now time.Duration) int {
+	type finished struct {
+		id  string
+		end time.Duration
+	}
+	evicted := 0
+	if limit := vs.Retention.MaxDeadLetters; limit > 0 && len(vs.DeadLetters) > limit {
+		for _, id := range vs.DeadLetters[:len(vs.DeadLetters)-limit] {
+			if _, ok := vs.Packets[id]; ok {
+				vs.retire(id)
+				evicted++
+			}
+		}
+		vs.DeadLetters = append([]string(nil), vs.DeadLetters[len(vs.DeadLetters)-limit:]...)
+	}
+	dead := make(map[string]bool, len(vs.DeadLetters))
+	for _, id := range vs.DeadLetters {
+		dead[id] = true
+	}
+
+	maxAge := vs.Retention.MaxAge()
+	var kept []finished
+	for id, p := range vs.Packets {
+		if !p.Finished() {
+			continue
+		}
+		end := p.finishedSim()
+		if maxAge > 0 && !dead[id] && now-end > maxAge {
+			vs.retire(id)
+			evicted++
+			continue
+		}
+		kept = append(kept, finished{id, end})
+	}
+
+	if limit := vs.Retention.MaxPackets; limit > 0 && len(kept) > limit {
+		sort.Slice(kept, func(i, j int) bool { return kept[i].end < kept[j].end })
+		for _, f := range kept[:len(kept)-limit] {
+			if dead[f.id] {
+				removeID(&vs.DeadLetters, f.id)
+			}
+			vs.retire(f.id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+func (vs *VisualState) retire(id string) {
+	p := vs.Packets[id]
+	vs.Retired.add(p, p.FinishedAt())
+	delete(vs.Packets, id)
+}
+
+func removeID(ids *[]string, id string) {
+	for i, other := range *ids {
+		if other == id {
+			*ids = append((*ids)[:i], (*ids)[i+1:]...)
+			return
+		}
+	}
+}
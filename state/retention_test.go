@@ -0,0 +1,120 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testClock es un reloj de simulación que el test mueve a mano.
+type testClock struct{ now time.Duration }
+
+func (c *testClock) Now() time.Duration { return c.now }
+
+// finishAt lleva un paquete nuevo a to (Done o Error) en el instante at del
+// reloj de vs.
+func finishAt(t *testing.T, vs *VisualState, clock *testClock, id string, to PacketStatus, at time.Duration) {
+	t.Helper()
+	from := AwaitingEcho
+	if to == Error {
+		from = SendingToAPI
+	}
+	p := &PacketState{ID: id, Sensor: "mpu", Status: from, Stage: "api", Attempt: 1, MaxAttempts: 1,
+		CreatedAt: time.Now()}
+	vs.Packets[id] = p
+	clock.now = at
+	if !vs.SetStatus(p, to) {
+		t.Fatalf("%s: %s → %s rechazada", id, from, to)
+	}
+}
+
+func newRetentionState(r RetentionConfig) (*VisualState, *testClock) {
+	clock := &testClock{}
+	return &VisualState{Packets: make(map[string]*PacketState), Retention: r, Clock: clock}, clock
+}
+
+func TestEvictByAge(t *testing.T) {
+	vs, clock := newRetentionState(RetentionConfig{MaxAgeMs: 1000})
+	finishAt(t, vs, clock, "viejo", Done, 0)
+	finishAt(t, vs, clock, "nuevo", Done, 900*time.Millisecond)
+	finishAt(t, vs, clock, "fallido", Error, 0)
+	vs.Packets["en curso"] = &PacketState{ID: "en curso", Status: SendingToAPI}
+
+	// La edad se mide con el reloj de la simulación, no con el de pared.
+	if n := vs.Evict(time.Second); n != 0 {
+		t.Fatalf("a 1s se retiraron %d, ningún terminado pasó de 1s", n)
+	}
+	if n := vs.Evict(1500 * time.Millisecond); n != 1 {
+		t.Fatalf("a 1.5s se retiraron %d, se esperaba 1", n)
+	}
+	for _, id := range []string{"nuevo", "fallido", "en curso"} {
+		if _, ok := vs.Packets[id]; !ok {
+			t.Errorf("'%s' se retiró", id)
+		}
+	}
+	// Dead letters no vence por edad.
+	vs.Evict(time.Hour)
+	if _, ok := vs.Packets["fallido"]; !ok || len(vs.DeadLetters) != 1 {
+		t.Errorf("el de dead letters venció por edad (DeadLetters %v)", vs.DeadLetters)
+	}
+}
+
+func TestEvictByCount(t *testing.T) {
+	vs, clock := newRetentionState(RetentionConfig{MaxPackets: 2})
+	finishAt(t, vs, clock, "a", Done, 1*time.Second)
+	finishAt(t, vs, clock, "b", Error, 2*time.Second)
+	finishAt(t, vs, clock, "c", Done, 3*time.Second)
+	finishAt(t, vs, clock, "d", Done, 4*time.Second)
+
+	if n := vs.Evict(5 * time.Second); n != 2 {
+		t.Fatalf("se retiraron %d, se esperaban 2", n)
+	}
+	if _, ok := vs.Packets["c"]; !ok {
+		t.Error("se retiró 'c', que está entre los dos más nuevos")
+	}
+	// El de dead letters cuenta para el límite y sale también de la lista.
+	if _, ok := vs.Packets["b"]; ok || len(vs.DeadLetters) != 0 {
+		t.Errorf("'b' sigue en memoria o en DeadLetters %v", vs.DeadLetters)
+	}
+}
+
+func TestEvictCapsDeadLetters(t *testing.T) {
+	vs, clock := newRetentionState(RetentionConfig{MaxDeadLetters: 3})
+	for i := 0; i < 5; i++ {
+		finishAt(t, vs, clock, fmt.Sprint(i), Error, time.Duration(i)*time.Second)
+	}
+	finishAt(t, vs, clock, "ok", Done, 0)
+
+	if n := vs.Evict(time.Hour); n != 2 {
+		t.Fatalf("se retiraron %d, se esperaban 2", n)
+	}
+	if got := fmt.Sprint(vs.DeadLetters); got != "[2 3 4]" {
+		t.Errorf("DeadLetters = %s, se esperaban los tres más nuevos", got)
+	}
+	if _, ok := vs.Packets["0"]; ok {
+		t.Error("'0' sigue en memoria")
+	}
+	if _, ok := vs.Packets["ok"]; !ok {
+		t.Error("sin max_packets ni max_age_ms se retiró un Done")
+	}
+}
+
+func TestEvictRetiredAggregate(t *testing.T) {
+	vs, clock := newRetentionState(RetentionConfig{MaxPackets: 1})
+	finishAt(t, vs, clock, "a", Done, 1*time.Second)
+	finishAt(t, vs, clock, "b", Error, 2*time.Second)
+	finishAt(t, vs, clock, "c", Done, 3*time.Second)
+	vs.Packets["a"].Sensor = "tfluna"
+
+	vs.Evict(3 * time.Second)
+	r := vs.Retired
+	if r.Packets != 2 || r.ByStatus[Done] != 1 || r.ByStatus[Error] != 1 {
+		t.Errorf("Retired = %d paquetes, por estado %v", r.Packets, r.ByStatus)
+	}
+	if r.BySensor["tfluna"] != 1 || r.BySensor["mpu"] != 1 {
+		t.Errorf("Retired por sensor %v", r.BySensor)
+	}
+	if r.Lifetime.Summary("vida").Count != 2 {
+		t.Errorf("Lifetime con %d muestras, se esperaban 2", r.Lifetime.Summary("vida").Count)
+	}
+}
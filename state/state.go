@@ -1,8 +1,12 @@
 package state
 
 import (
+	"context"
+	"fmt"
+	"geova-simulation/metrics"
 	"image/color"
 	"sync"
+	"time"
 )
 
 type PacketStatus int
@@ -11,41 +15,292 @@ const (
 	Idle PacketStatus = iota
 	SendingToAPI
 	ArrivedAtAPI
-	ProcessingAtAPI
-	SendingToRabbit
-	ProcessingAtRabbit
-	SendingToWebsocket
-	ProcessingAtWebsocket
-	SendingToFrontend
+	SendingToStage    // en camino a Stage
+	ProcessingAtStage // retenido en Stage (procesando o sin ack de la cola)
 	Done
 	Error
+	Retrying
+	Cancelled
+	AwaitingEcho   // en el frontend, esperando el mensaje del WebSocket
+	Lost           // la API lo aceptó pero su mensaje nunca llegó
+	WaitingAtStage // en la fila de Stage, sin lugar para procesarse
+	Dropped        // descartado por backpressure con la fila llena
 )
 
+var statusNames = [...]string{
+	"Idle", "SendingToAPI", "ArrivedAtAPI", "SendingToStage", "ProcessingAtStage",
+	"Done", "Error", "Retrying", "Cancelled", "AwaitingEcho", "Lost",
+	"WaitingAtStage", "Dropped",
+}
+
+func (s PacketStatus) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("PacketStatus(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
 type PacketState struct {
 	ID               string
+	Sensor           string
+	ProjectID        int
+	DeviceID         string
 	Active           bool
 	X, Y             float64
 	TargetX, TargetY float64
 	Color            color.Color
 	Status           PacketStatus
 	Payload          interface{}
-	ProcessingTimer  int
+	Stage            string // ID de la etapa de la topología donde está o hacia donde va
+	ProcessingTimer  time.Duration
+	Attempt          int
+	MaxAttempts      int
+
+	// Batch es el número de batch del dispositivo y CorrelationID el ID que
+	// viaja en X-Request-ID, para buscar el paquete en los logs de la API.
+	Batch         int
+	CorrelationID string
+	// LastStatusCode y LastError son el resultado del último intento HTTP.
+	LastStatusCode int
+	LastError      string
+	// GenStart y GenEnd encierran la generación de la lectura (cero si no
+	// se generó en esta corrida, p. ej. en un replay). CreatedAt es cuándo
+	// lo registró el worker.
+	GenStart, GenEnd time.Time
+	CreatedAt        time.Time
+	// History son los últimos cambios de estado, del más viejo al más nuevo.
+	History []StatusChange
+
+	// FailureReason explica por qué terminó en Error. RetryOf y RedrivenAs
+	// ligan un reenvío manual desde dead letters con el paquete original.
+	FailureReason string
+	RetryOf       string
+	RedrivenAs    string
+}
+
+// LogTag es el prefijo de los mensajes de consola del paquete.
+func (p *PacketState) LogTag() string {
+	if p.CorrelationID == "" {
+		return p.ID
+	}
+	return p.ID + " rid=" + p.CorrelationID
+}
+
+// Finished indica si el paquete ya no va a cambiar de estado.
+func (p *PacketState) Finished() bool {
+	switch p.Status {
+	case Done, Error, Cancelled, Lost, Dropped:
+		return true
+	}
+	return false
+}
+
+// Observer recibe los eventos de cada paquete (p. ej. para grabar la
+// sesión). PacketCreated y Transition se llaman con Mutex tomado, así que no
+// deben volver a tomarlo.
+type Observer interface {
+	PacketCreated(p *PacketState)
+	AttemptDone(p *PacketState, attempt, statusCode int, latency time.Duration, err error)
+	Transition(p *PacketState, from, to PacketStatus)
+	// Phase marca un tramo del worker que no cambia el estado del paquete
+	// (serializar, la espera artificial antes de enviar).
+	Phase(p *PacketState, name string, start, end time.Time)
+}
+
+// Observers reparte cada evento entre varios Observer, en orden.
+type Observers []Observer
+
+func (os Observers) PacketCreated(p *PacketState) {
+	for _, o := range os {
+		o.PacketCreated(p)
+	}
+}
+
+func (os Observers) AttemptDone(p *PacketState, attempt, statusCode int, latency time.Duration, err error) {
+	for _, o := range os {
+		o.AttemptDone(p, attempt, statusCode, latency, err)
+	}
+}
+
+func (os Observers) Transition(p *PacketState, from, to PacketStatus) {
+	for _, o := range os {
+		o.Transition(p, from, to)
+	}
+}
+
+func (os Observers) Phase(p *PacketState, name string, start, end time.Time) {
+	for _, o := range os {
+		o.Phase(p, name, start, end)
+	}
+}
+
+// Stats acumula contadores de toda la corrida; se actualizan con Mutex tomado.
+type Stats struct {
+	Created   int // paquetes lanzados por los workers
+	Succeeded int // la API respondió sin error
+	Failed    int // agotaron los intentos o el error no era reintentable
+	Retries   int // reintentos realizados
+	Cancelled int // abortados al detener la simulación
+	Delivered int // llegaron al frontend (Done)
+	Lost      int // sin confirmación del WebSocket a tiempo
+	Dropped   int // descartados por backpressure
+	Redriven  int // reenviados a mano desde dead letters
+	Rejected  int // transiciones que la Machine no permitió
+}
+
+// ErrorRate es la fracción de paquetes resueltos que terminaron en error.
+func (s Stats) ErrorRate() float64 {
+	resolved := s.Succeeded + s.Failed
+	if resolved == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(resolved)
+}
+
+// ProjectInfo identifica un proyecto en el dashboard.
+type ProjectInfo struct {
+	ID   int
+	Name string
+}
+
+// DeviceInfo es lo que la ventana necesita de cada trípode de la flota.
+type DeviceInfo struct {
+	ID           string
+	ProjectID    int
+	Manual       bool // su inclinación es CurrentTilt/CurrentPitch
+	LaneY, Scale float64
+	Tilt, Pitch  float64
+}
+
+// LoadInfo describe el modo de carga mientras está activo.
+type LoadInfo struct {
+	Enabled    bool
+	TargetRate float64 // req/s por sensor en este momento (sube durante el ramp-up)
+	InFlight   int     // paquetes ocupando un lugar del límite de concurrencia
+	Waiting    int     // paquetes esperando un lugar libre
+}
+
+// Readings son los últimos valores de un proyecto que llegaron al frontend.
+type Readings struct {
+	DisplayDistancia float64
+	DisplayRoll      float64
+	DisplayNitidez   float64
 }
 
 type VisualState struct {
 	Mutex   sync.Mutex
 	Packets map[string]*PacketState
 
-	PythonAPITimer    int
-	RabbitMQTimer     int
-	WebsocketAPITimer int
+	// StageActivity es el tiempo simulado que le queda a la animación de
+	// cada etapa (por ID).
+	StageActivity map[string]time.Duration
+	// StageLines son las filas de espera de cada etapa: IDs de paquetes en
+	// WaitingAtStage, el primero es el próximo en entrar.
+	StageLines map[string][]string
+	// DeadLetters son los paquetes en Error que esperan un reenvío manual,
+	// en el orden en que fallaron.
+	DeadLetters []string
+
+	Projects        []ProjectInfo
+	Readings        map[int]*Readings
+	SelectedProject int // índice en Projects que muestra el dashboard
+	Devices         []DeviceInfo
 
-	DisplayDistancia   float64
-	DisplayRoll        float64
-	DisplayNitidez     float64
 	CurrentTilt        float64
+	CurrentPitch       float64
 	SimulacionIniciada bool
 
-	StopChan chan struct{}
+	Cancel   context.CancelFunc
 	PacketID int
+	Stats    Stats
+
+	// Latency tiene un histograma por endpoint; usa su propio lock, así que
+	// no hace falta tomar Mutex para registrar ni leer.
+	Latency *metrics.LatencySet
+	Load    LoadInfo
+
+	// Observer es opcional (nil = nadie escucha).
+	Observer Observer
+	// Machine valida los cambios de estado (nil = DefaultMachine).
+	Machine *Machine
+
+	// Retention acota los paquetes terminados en Packets (ver Evict) y
+	// Retired resume los que ya salieron.
+	Retention RetentionConfig
+	Retired   Retired
+	// Clock es el reloj de la simulación con el que se anota History y se
+	// mide la edad de los terminados (nil = reloj de pared).
+	Clock Clock
+}
+
+// Clock da el tiempo simulado transcurrido; lo implementa simulation.Clock.
+type Clock interface {
+	Now() time.Duration
+}
+
+var wallEpoch = time.Now()
+
+// SimNow es Clock.Now(), o el tiempo de pared desde el arranque sin Clock.
+func (vs *VisualState) SimNow() time.Duration {
+	if vs.Clock == nil {
+		return time.Since(wallEpoch)
+	}
+	return vs.Clock.Now()
+}
+
+// SetStatus pasa el paquete a to si la Machine lo permite: corre las
+// acciones de salida y de entrada, lo anota en History y avisa al Observer.
+// Una transición rechazada se registra y el paquete no cambia. Se llama con
+// Mutex tomado.
+func (vs *VisualState) SetStatus(p *PacketState, to PacketStatus) bool {
+	from := p.Status
+	if from == to {
+		return true
+	}
+	m := vs.Machine
+	if m == nil {
+		m = defaultMachine
+	}
+	if err := m.Check(p, from, to); err != nil {
+		vs.Stats.Rejected++
+		fmt.Printf("[FSM] [%s] Transición %s → %s rechazada: %v\n", p.LogTag(), from, to, err)
+		return false
+	}
+
+	for _, a := range m.exit[from] {
+		a(vs, p)
+	}
+	p.Status = to
+	if len(p.History) == maxHistory {
+		p.History = append(p.History[:0], p.History[1:]...)
+	}
+	p.History = append(p.History, StatusChange{At: time.Now(), SimAt: vs.SimNow(), From: from, To: to, Stage: p.Stage})
+	for _, a := range m.enter[to] {
+		a(vs, p)
+	}
+	if vs.Observer != nil {
+		vs.Observer.Transition(p, from, to)
+	}
+	return true
+}
+
+// ReadingsFor retorna (creando si hace falta) las lecturas del proyecto.
+func (vs *VisualState) ReadingsFor(projectID int) *Readings {
+	if vs.Readings == nil {
+		vs.Readings = make(map[int]*Readings)
+	}
+	r, ok := vs.Readings[projectID]
+	if !ok {
+		r = &Readings{}
+		vs.Readings[projectID] = r
+	}
+	return r
+}
+
+// SelectedProjectInfo retorna el proyecto que muestra el dashboard.
+func (vs *VisualState) SelectedProjectInfo() ProjectInfo {
+	if len(vs.Projects) == 0 {
+		return ProjectInfo{}
+	}
+	return vs.Projects[vs.SelectedProject%len(vs.Projects)]
 }
@@ -0,0 +1,48 @@
+//go:build !nogui
+
+package main
+
+import (
+	"geova-simulation/assets"
+	"geova-simulation/game"
+	"geova-simulation/pipeline"
+	"image"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Constantes Globales ---
+const (
+	windowWidth  = 900
+	windowHeight = 650
+)
+
+// runWindow abre la ventana de Ebitengine sobre pl y retorna al cerrarla.
+func runWindow(pl *pipeline.Pipeline) {
+	// Cargar todos los Assets
+	// Llama a la función LoadAssets que definimos en el paquete 'assets'
+	gameAssets := assets.LoadAssets()
+	log.Println("✅ Todos los assets cargados.")
+
+	// Crear la Instancia del Juego
+	// Define la "zona de clic" para el botón de crear
+	// (Ajusta estos números para mover tu botón)
+	btnX0 := float64(windowWidth - 120)                                          // Esquina derecha
+	btnY0 := float64(windowHeight - 60)                                          // Abajo
+	btnRect := image.Rect(int(btnX0), int(btnY0), int(btnX0+100), int(btnY0+40)) // (100x40 de tamaño)
+
+	juego := game.NewGame(gameAssets, pl, btnRect)
+
+	// Configurar y Correr Ebitengine
+	ebiten.SetWindowSize(windowWidth, windowHeight)
+	ebiten.SetWindowTitle("Simulación de Flujo Geova (Concurrente)")
+
+	log.Println("🚀 Iniciando simulación...")
+
+	// ebiten.RunGame toma control del hilo principal
+	// y empezará a llamar a juego.Update() y juego.Draw()
+	if err := ebiten.RunGame(juego); err != nil {
+		log.Fatal(err)
+	}
+}
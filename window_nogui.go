@@ -0,0 +1,14 @@
+//go:build nogui
+
+package main
+
+import (
+	"geova-simulation/pipeline"
+	"log"
+)
+
+// runWindow no está disponible en los binarios compilados con -tags nogui,
+// que no dependen de Ebitengine (ni de X11 en Linux).
+func runWindow(*pipeline.Pipeline) {
+	log.Fatal("compilado sin ventana (-tags nogui): usá -headless")
+}